@@ -0,0 +1,31 @@
+package dsv
+
+type dcomment struct {
+	ok    bool
+	value func(string)
+}
+
+// DCommentHandler sets a function receiving the text after CommentPrefix of
+// every comment line skipped by DeserializeMapIndex.
+func DCommentHandler(f func(string)) dcomment {
+	return dcomment{ok: true, value: f}
+}
+
+// SerializeWithComments is Serialize with each comment written on its own
+// line, prefixed by CommentPrefix, ahead of the header. Comments read with
+// OnComment carry no position, so comments from between records move to
+// the top when a file is written back.
+func (d dsvi) SerializeWithComments(src interface{}, comments []string) ([]byte, error) {
+	bs := []byte{}
+	if len(comments) > 0 && d.cplen == 0 {
+		return bs, DSV_COMMENT_PREFIX_NZ
+	}
+	for _, c := range comments {
+		bs = append(append(append(bs, d.commentPrefix...), c...), d.lineEnding...)
+	}
+	ds, e := d.serialize(src)
+	if e != nil {
+		return bs, e
+	}
+	return d.output(append(bs, ds...))
+}
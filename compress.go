@@ -0,0 +1,125 @@
+package dsv
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	magicGzip  = []byte{0x1f, 0x8b}
+	magicBzip2 = []byte("BZh")
+)
+
+// isZlib checks the zlib header, a deflate method with a 32K window and a
+// valid check value. Text rarely matches but decompress falls back to the raw
+// bytes when it does.
+func isZlib(s []byte) bool {
+	return len(s) >= 2 && s[0] == 0x78 && (uint(s[0])<<8|uint(s[1]))%31 == 0
+}
+
+// decompress inflates gzip, zlib or bzip2 input found by its magic bytes,
+// anything else is returned as is. The inflated size is bounded by
+// MaxInputBytes. The bzip2 and zlib magic bytes are also plain text, eg a
+// header starting with "BZh9", so input that does not inflate is returned as
+// is rather than reported.
+func (d dsvi) decompress(s []byte) ([]byte, error) {
+	var r io.Reader
+	switch {
+	case !d.decompressInput:
+		return s, nil
+	case bytes.HasPrefix(s, magicGzip):
+		gr, e := gzip.NewReader(bytes.NewReader(s))
+		if e != nil {
+			return nil, DSV_DECOMPRESS_ERROR.enhance(e)
+		}
+		return d.inflate(gr)
+	case bytes.HasPrefix(s, magicBzip2) && len(s) > 3 && s[3] >= '1' && s[3] <= '9':
+		r = bzip2.NewReader(bytes.NewReader(s))
+	case isZlib(s):
+		zr, e := zlib.NewReader(bytes.NewReader(s))
+		if e != nil {
+			return s, nil
+		}
+		r = zr
+	default:
+		return s, nil
+	}
+	out, e := d.inflate(r)
+	if errors.Is(e, DSV_INPUT_TOO_LARGE) {
+		return nil, e
+	}
+	if e != nil {
+		return s, nil
+	}
+	return out, nil
+}
+
+// inflate reads r to the end, stopping once MaxInputBytes is passed.
+func (d dsvi) inflate(r io.Reader) ([]byte, error) {
+	if d.maxInputBytes > 0 {
+		r = io.LimitReader(r, int64(d.maxInputBytes)+1)
+	}
+	out, e := io.ReadAll(r)
+	if e != nil {
+		return nil, DSV_DECOMPRESS_ERROR.enhance(e)
+	}
+	if d.maxInputBytes > 0 && len(out) > d.maxInputBytes {
+		return nil, DSV_INPUT_TOO_LARGE.enhance(fmt.Errorf("decompressed input is over %d bytes", d.maxInputBytes))
+	}
+	return out, nil
+}
+
+// compress gzips Serialize output when WriteGzip is set.
+func (d dsvi) compress(s []byte) ([]byte, error) {
+	if !d.writeGzip {
+		return s, nil
+	}
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	if _, e := w.Write(s); e != nil {
+		return nil, e
+	}
+	if e := w.Close(); e != nil {
+		return nil, e
+	}
+	return b.Bytes(), nil
+}
+
+// input undoes compression and character encoding so s can be parsed.
+func (d dsvi) input(s []byte) ([]byte, error) {
+	s, e := d.decompress(s)
+	if e != nil {
+		return nil, e
+	}
+	return d.decode(s)
+}
+
+// output applies the character encoding and compression to Serialize output.
+func (d dsvi) output(s []byte) ([]byte, error) {
+	s, e := d.encode(s)
+	if e != nil {
+		return nil, e
+	}
+	return d.compress(s)
+}
+
+// DeserializeReader is Deserialize reading its input from r, compressed
+// input is detected as it is with Deserialize.
+func (d dsvi) DeserializeReader(r io.Reader, tgt interface{}) error {
+	if d.maxInputBytes > 0 {
+		r = io.LimitReader(r, int64(d.maxInputBytes)+1)
+	}
+	s, e := io.ReadAll(r)
+	if e != nil {
+		return DSV_DESERIALIZE_ERROR.enhance(e)
+	}
+	if d.maxInputBytes > 0 && len(s) > d.maxInputBytes {
+		return DSV_INPUT_TOO_LARGE.enhance(fmt.Errorf("input is over %d bytes", d.maxInputBytes))
+	}
+	return d.Deserialize(s, tgt)
+}
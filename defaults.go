@@ -2,6 +2,7 @@ package dsv
 
 import (
 	"fmt"
+	"reflect"
 	"strconv"
 	"strings"
 )
@@ -15,20 +16,20 @@ func ifunc(s string, _ []byte) (interface{}, bool) {
 }
 func i8func(s string, _ []byte) (interface{}, bool) {
 	i, e := ifunc(s, []byte{})
-	return int8(i.(int64)), e
+	return int8(i.(int)), e
 }
 func i16func(s string, _ []byte) (interface{}, bool) {
 	i, e := ifunc(s, []byte{})
-	return int8(i.(int64)), e
+	return int16(i.(int)), e
 }
 func i32func(s string, _ []byte) (interface{}, bool) {
 	i, e := ifunc(s, []byte{})
-	return int8(i.(int64)), e
+	return int32(i.(int)), e
 }
 func uifunc(s string, _ []byte) (interface{}, bool) {
 	u, e := strconv.ParseUint(s, 10, 64)
 	if e != nil {
-		return 0, false
+		return uint64(0), false
 	}
 	return u, true
 }
@@ -38,16 +39,16 @@ func ui8func(s string, _ []byte) (interface{}, bool) {
 }
 func ui16func(s string, _ []byte) (interface{}, bool) {
 	i, e := uifunc(s, []byte{})
-	return uint8(i.(uint64)), e
+	return uint16(i.(uint64)), e
 }
 func ui32func(s string, _ []byte) (interface{}, bool) {
 	i, e := uifunc(s, []byte{})
-	return uint8(i.(uint64)), e
+	return uint32(i.(uint64)), e
 }
 func ffunc(s string, _ []byte) (interface{}, bool) {
 	f, e := strconv.ParseFloat(s, 64)
 	if e != nil {
-		return 0, false
+		return float64(0), false
 	}
 	return f, true
 }
@@ -59,7 +60,7 @@ func f32func(s string, _ []byte) (interface{}, bool) {
 func cfunc(s string, _ []byte) (interface{}, bool) {
 	c, e := strconv.ParseComplex(s, 128)
 	if e != nil {
-		return 0, false
+		return complex128(0), false
 	}
 	return c, true
 }
@@ -147,87 +148,107 @@ func uint8deser(s string, _ []byte) (interface{}, bool) {
 }
 
 var (
-	DefaultDeserializers = map[string]func(string, []byte) (interface{}, bool){
-		"bool": func(s string, _ []byte) (interface{}, bool) {
+	// DefaultTypeDeserializers and DefaultTypeSerializers are the converters
+	// every dsvi starts with, keyed by type.
+	DefaultTypeDeserializers = map[reflect.Type]func(string, []byte) (interface{}, bool){
+		reflect.TypeOf(false): func(s string, _ []byte) (interface{}, bool) {
 			if s == "1" || s == "t" || s == "true" {
 				return true, true
 			}
 			return false, true
 		},
-		"string": func(s string, _ []byte) (interface{}, bool) {
+		reflect.TypeOf(""): func(s string, _ []byte) (interface{}, bool) {
 			return s, true
 		},
-		"[]int8":  int8deser,
-		"int":     ifunc,
-		"int8":    i8func,
-		"int16":   i16func,
-		"int32":   i32func,
-		"int64":   ifunc,
-		"[]uint8": uint8deser,
-		"uint":    uifunc,
-		"uint8":   ui8func,
-		"uint16":  ui16func,
-		"uint32":  ui32func,
-		"uint64":  uifunc,
-		"uintptr": uifunc,
-		"byte": func(_ string, bs []byte) (interface{}, bool) {
-			if len(bs) != 1 {
-				return byte(0), false
-			}
-			return bs[0], true
-		},
-		"rune":       ifunc,
-		"float32":    f32func,
-		"float64":    ffunc,
-		"complex64":  cfunc,
-		"complex128": cfunc,
+		reflect.TypeOf([]int8{}):      int8deser,
+		reflect.TypeOf(int(0)):        ifunc,
+		reflect.TypeOf(int8(0)):       i8func,
+		reflect.TypeOf(int16(0)):      i16func,
+		reflect.TypeOf(int32(0)):      i32func,
+		reflect.TypeOf(int64(0)):      ifunc,
+		reflect.TypeOf([]uint8{}):     uint8deser,
+		reflect.TypeOf(uint(0)):       uifunc,
+		reflect.TypeOf(uint8(0)):      ui8func,
+		reflect.TypeOf(uint16(0)):     ui16func,
+		reflect.TypeOf(uint32(0)):     ui32func,
+		reflect.TypeOf(uint64(0)):     uifunc,
+		reflect.TypeOf(uintptr(0)):    uifunc,
+		reflect.TypeOf(float32(0)):    f32func,
+		reflect.TypeOf(float64(0)):    ffunc,
+		reflect.TypeOf(complex64(0)):  cfunc,
+		reflect.TypeOf(complex128(0)): cfunc,
 	}
 
-	// TODO: these need to escape output
-	DefaultSerializers = map[string]func(interface{}) ([]byte, bool){
-		"string": func(i interface{}) ([]byte, bool) {
+	DefaultTypeSerializers = map[reflect.Type]func(interface{}) ([]byte, bool){
+		reflect.TypeOf(""): func(i interface{}) ([]byte, bool) {
 			switch i.(type) {
 			case string:
 				return []byte(i.(string)), true
 			}
 			return []byte{}, false
 		},
-		"float32": floatser,
-		"float64": floatser,
-		"bool": func(i interface{}) ([]byte, bool) {
+		reflect.TypeOf(float32(0)): floatser,
+		reflect.TypeOf(float64(0)): floatser,
+		reflect.TypeOf(false): func(i interface{}) ([]byte, bool) {
 			switch i.(type) {
 			case bool:
 				return []byte(fmt.Sprintf("%t", i.(bool))), true
 			}
 			return []byte{}, false
 		},
-		"[]int":    intser,
-		"[]int8":   intser,
-		"[]int16":  intser,
-		"[]int32":  intser,
-		"[]int64":  intser,
-		"[]uint":   intser,
-		"[]uint8":  intser,
-		"[]uint16": intser,
-		"[]uint32": intser,
-		"[]uint64": intser,
-		"int":      intser,
-		"int8":     intser,
-		"int16":    intser,
-		"int32":    intser,
-		"int64":    intser,
-		"uint":     intser,
-		"uint8":    intser,
-		"uint16":   intser,
-		"uint32":   intser,
-		"uint64":   intser,
-		"[]byte": func(i interface{}) ([]byte, bool) {
-			// TODO escape output
-			switch i.(type) {
-			case []byte:
-				return i.([]byte), true
-			}
-			return []byte{}, false
-		},
+		reflect.TypeOf([]int{}):    intser,
+		reflect.TypeOf([]int8{}):   intser,
+		reflect.TypeOf([]int16{}):  intser,
+		reflect.TypeOf([]int32{}):  intser,
+		reflect.TypeOf([]int64{}):  intser,
+		reflect.TypeOf([]uint{}):   intser,
+		reflect.TypeOf([]uint8{}):  intser,
+		reflect.TypeOf([]uint16{}): intser,
+		reflect.TypeOf([]uint32{}): intser,
+		reflect.TypeOf([]uint64{}): intser,
+		reflect.TypeOf(int(0)):     intser,
+		reflect.TypeOf(int8(0)):    intser,
+		reflect.TypeOf(int16(0)):   intser,
+		reflect.TypeOf(int32(0)):   intser,
+		reflect.TypeOf(int64(0)):   intser,
+		reflect.TypeOf(uint(0)):    intser,
+		reflect.TypeOf(uint8(0)):   intser,
+		reflect.TypeOf(uint16(0)):  intser,
+		reflect.TypeOf(uint32(0)):  intser,
+		reflect.TypeOf(uint64(0)):  intser,
 	}
 )
+
+// DefaultDeserializers and DefaultSerializers hold the same converters keyed
+// by type name, eg "int64", as they were before the registries were keyed by
+// type. Entries added or replaced here apply to every dsvi created afterwards,
+// the same way as DSVOpt.Deserializers and DSVOpt.Serializers.
+var (
+	DefaultDeserializers = map[string]func(string, []byte) (interface{}, bool){}
+	DefaultSerializers   = map[string]func(interface{}) ([]byte, bool){}
+)
+
+// SanitizedStringSerializer is the default string serializer with formulas
+// neutralised by SanitizeFormula, for code writing cells without Serialize.
+var SanitizedStringSerializer = SanitizeSerializer(DefaultTypeSerializers[reflect.TypeOf("")])
+
+// SanitizeSerializer wraps a serializer so its output goes through
+// SanitizeFormula, eg SanitizeSerializer(DefaultSerializers["string"]).
+func SanitizeSerializer(f func(interface{}) ([]byte, bool)) func(interface{}) ([]byte, bool) {
+	return func(i interface{}) ([]byte, bool) {
+		v, ok := f(i)
+		if !ok {
+			return v, ok
+		}
+		return SanitizeFormula(v), true
+	}
+}
+
+func init() {
+	for t, f := range DefaultTypeDeserializers {
+		DefaultDeserializers[t.String()] = f
+	}
+	for t, f := range DefaultTypeSerializers {
+		DefaultSerializers[t.String()] = f
+	}
+}
@@ -0,0 +1,109 @@
+package dsv
+
+// The dialect constructors return a DSVOpt describing a well known format,
+// fields may be changed before it is handed to NewDSV, eg
+//
+//	opt := dsv.PostgresText()
+//	opt.ParseHeader = dsv.DBool(true)
+//	d, e := dsv.NewDSV(opt)
+
+// RFC4180 is comma separated with CRLF line endings, fields are quoted with
+// `"` when needed and quotes inside them are doubled. Whitespace is kept and
+// a quote that is never closed is an error.
+func RFC4180() DSVOpt {
+	return DSVOpt{
+		FieldDelimiter: DString(","),
+		LineSeparator:  DString("\r\n"),
+		FieldOperator:  DString("\""),
+		EscapeOperator: DString(""),
+		DoubleQuote:    DBool(true),
+		StripField:     DString(""),
+		StrictQuotes:   DBool(true),
+	}
+}
+
+// Excel is the CSV Excel writes as "CSV UTF-8": RFC4180 quoting with CRLF
+// line endings and a UTF-8 byte order mark, without which Excel reads the
+// file in the system code page. It reads as Excel does, records may end in
+// \r\n, \n or \r and a quote inside an unquoted field is a literal.
+func Excel() DSVOpt {
+	opt := RFC4180()
+	opt.StrictQuotes = DBool(false)
+	opt.LazyQuotes = DBool(true)
+	opt.UniversalNewline = DBool(true)
+	opt.LineEnding = DString("\r\n")
+	opt.WriteBOM = DBool(true)
+	return opt
+}
+
+// Unix is comma separated with LF line endings and every field quoted, as
+// Python's unix dialect writes it.
+func Unix() DSVOpt {
+	opt := RFC4180()
+	opt.LineSeparator = DString("\n")
+	opt.QuoteAll = DBool(true)
+	return opt
+}
+
+// TSV is tab separated with LF line endings and no quoting, tabs, newlines
+// and backslashes in fields are written as \t, \n and \\.
+func TSV() DSVOpt {
+	return DSVOpt{
+		FieldDelimiter:  DString("\t"),
+		LineSeparator:   DString("\n"),
+		FieldOperator:   DString(""),
+		EscapeOperator:  DString("\\"),
+		EscapeSequences: DEscapes(EscapesTSV),
+		StripField:      DString(""),
+	}
+}
+
+// PostgresText is the default text format of Postgres COPY, TSV without a
+// header where `\N` is NULL. Backspace, form feed and vertical tab are also
+// written as \b, \f and \v, and octal and \x hex escapes are read.
+func PostgresText() DSVOpt {
+	opt := TSV()
+	opt.ParseHeader = DBool(false)
+	opt.EscapeSequences = DEscapes(EscapesPostgres)
+	opt.NullString = DString(`\N`)
+	return opt
+}
+
+// PostgresCSV is the CSV format of Postgres COPY, an unquoted empty field is
+// NULL and a quoted one is an empty string.
+func PostgresCSV() DSVOpt {
+	opt := RFC4180()
+	opt.LineSeparator = DString("\n")
+	opt.ParseHeader = DBool(false)
+	opt.NullString = DString("")
+	return opt
+}
+
+// MySQL is the default format of LOAD DATA and SELECT ... INTO OUTFILE, tab
+// separated without a header where `\N` is NULL. NUL, backspace and 0x1A are
+// written as \0, \b and \Z alongside \t, \n and \r.
+func MySQL() DSVOpt {
+	return DSVOpt{
+		FieldDelimiter:  DString("\t"),
+		LineSeparator:   DString("\n"),
+		FieldOperator:   DString(""),
+		EscapeOperator:  DString("\\"),
+		EscapeSequences: DEscapes(EscapesMySQL),
+		StripField:      DString(""),
+		ParseHeader:     DBool(false),
+		NullString:      DString(`\N`),
+	}
+}
+
+// ASCII separates fields with the unit separator (0x1F) and records with the
+// record separator (0x1E), neither is expected in the data so there is no
+// quoting or escaping.
+func ASCII() DSVOpt {
+	return DSVOpt{
+		FieldDelimiter: DString("\x1f"),
+		LineSeparator:  DString("\x1e"),
+		FieldOperator:  DString(""),
+		EscapeOperator: DString(""),
+		StripField:     DString(""),
+	}
+}
@@ -4,8 +4,13 @@ import (
 	"bytes"
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 )
 
+// dsvi holds a parsed DSVOpt. Each instance owns its own copy of the
+// serializer and deserializer registries and is never mutated after NewDSV
+// returns, so a single dsvi is safe for concurrent use by multiple goroutines.
 type dsvi struct {
 	fieldDelimiter []byte
 	lineSeparator  []byte
@@ -16,27 +21,81 @@ type dsvi struct {
 	strictMap      bool
 	stripField     []byte
 	skipEmptyRow   bool
-	serializers    map[string]func(interface{}) ([]byte, bool)
-	deserializers  map[string]func(string, []byte) (interface{}, bool)
+	serializers    map[reflect.Type]func(interface{}) ([]byte, bool)
+	deserializers  map[reflect.Type]func(string, []byte) (interface{}, bool)
 
-	escapedDelimiter []byte
-	escapedOperator  []byte
-	escapedSeparator []byte
+	// fieldDelimiters holds every accepted delimiter, longest first
+	fieldDelimiters     [][]byte
+	whitespaceDelimiter bool
+	universalNewline    bool
+	legacyQuoting       bool
+	strictQuotes        bool
+	maxInputBytes       int
+	maxFieldBytes       int
+	maxFields           int
+	maxRecords          int
+	inputEncoding       Encoding
+	outputEncoding      Encoding
+	writeBOM            bool
+	invalidUTF8         InvalidUTF8
+	decompressInput     bool
+	writeGzip           bool
+	sanitizeFormulas    bool
+	formulaColumns      map[string]bool
+	lazyQuotes          bool
+	lineEnding          []byte
+	serializersByName   map[string]func(interface{}) ([]byte, bool)
+	deserializersByName map[string]func(string, []byte) (interface{}, bool)
+	converters          map[string]Converter
+	includeColumns      []string
+	excludeColumns      map[string]bool
+	foldHeaderCase      bool
+	collapseHeader      bool
+	headerNormalizer    func(string) string
+	duplicateHeaders    DuplicateHeader
+	strictHeader        bool
+	skipRows            int
+	skipUntil           func([]string) bool
+	headerRow           int
+	headerRows          int
+	headerSeparator     []byte
+	footerRows          int
+	trailerMatch        func([]string) bool
+	trailerCount        func([][]string) (int, bool)
+	commentPrefix       []byte
+	onComment           func(string)
+	quoteAll            bool
+	escapeSequences     Escapes
+	nullString          []byte
+	hasNull             bool
+	fixedWidth          bool
+
+	escapedOperator []byte
+	doubledOperator []byte
+	escapedEscape   []byte
+	doubleQuote     bool
 
 	lslen int
 	eolen int
 	folen int
 	fdlen int
+	cplen int
 
-	escdlen int
 	escolen int
-	escslen int
 }
 
 type dbyte struct {
 	ok    bool
 	value []byte
 }
+type dint struct {
+	ok    bool
+	value int
+}
+type dpred struct {
+	ok    bool
+	value func([]string) bool
+}
 type dbool struct {
 	ok    bool
 	value bool
@@ -49,6 +108,18 @@ type ddeserial struct {
 	ok    bool
 	value map[string]func(string, []byte) (interface{}, bool)
 }
+type dstrings struct {
+	ok    bool
+	value []string
+}
+type dtserial struct {
+	ok    bool
+	value map[reflect.Type]func(interface{}) ([]byte, bool)
+}
+type dtdeserial struct {
+	ok    bool
+	value map[reflect.Type]func(string, []byte) (interface{}, bool)
+}
 
 type DSVOpt struct {
 	FieldDelimiter dbyte
@@ -56,13 +127,129 @@ type DSVOpt struct {
 	FieldOperator  dbyte
 	EscapeCombined dbyte
 	EscapeOperator dbyte
-	ParseHeader    dbool
-	UseCache       dbool
-	StrictMap      dbool
-	SkipEmptyRow   dbool
-	StripField     dbyte
-	Serializers    dserial
-	Deserializers  ddeserial
+	// FieldDelimiters accepts any of the listed delimiters when reading, the
+	// first is written unless FieldDelimiter is also set. WhitespaceDelimiter
+	// treats a run of spaces and tabs as one delimiter and ignores it at the
+	// start and end of a record, as awk does, and writes a single space.
+	FieldDelimiters     dstrings
+	WhitespaceDelimiter dbool
+	// UniversalNewline ends a record at any of \r\n, \n or \r outside of a
+	// quoted field instead of at LineSeparator. LineEnding is written after
+	// each record by Serialize, LineSeparator by default.
+	UniversalNewline dbool
+	LineEnding       dbyte
+	// LegacyQuoting restores the old field normalisation, where an empty
+	// quoted field keeps its quotes and escapes are removed inside quotes.
+	LegacyQuoting dbool
+	// StrictQuotes fails with DSV_UNTERMINATED_QUOTE, giving the position of
+	// the quote, when a quoted field is never closed rather than reading the
+	// rest of the input into it, and with DSV_MISPLACED_QUOTE for a quote
+	// inside an unquoted field or a closing quote followed by anything but a
+	// delimiter or line separator. LazyQuotes reads a FieldOperator as a literal
+	// unless it starts a field or closes one before a delimiter or line
+	// separator, and reads an unterminated opening quote as a literal too.
+	StrictQuotes dbool
+	LazyQuotes   dbool
+	// MaxInputBytes, MaxFieldBytes, MaxFields and MaxRecords bound the input,
+	// the raw bytes of one field, the fields in one record and the records,
+	// including the header, that will be parsed. Going over a limit fails with
+	// DSV_INPUT_TOO_LARGE, DSV_FIELD_TOO_LARGE, DSV_TOO_MANY_FIELDS or
+	// DSV_TOO_MANY_RECORDS. Zero is no limit.
+	MaxInputBytes dint
+	MaxFieldBytes dint
+	MaxFields     dint
+	MaxRecords    dint
+	// SanitizeFormulas makes Serialize write cells starting with =, +, -, @,
+	// tab or carriage return with a leading ' and quoted, so spreadsheets do not
+	// run them as formulas. Numeric fields are left alone, as are the tags in
+	// FormulaColumns, eg string columns holding negative numbers. Code calling
+	// serializers directly gets the same prefix from SanitizedStringSerializer
+	// or SanitizeSerializer.
+	SanitizeFormulas dbool
+	FormulaColumns   dstrings
+	// InputEncoding and OutputEncoding convert from and to UTF-8, a byte order
+	// mark at the start of the input is always removed and decides between
+	// UTF-8 and UTF-16. WriteBOM starts Serialize output with a byte order mark
+	// for UTF-8 and UTF-16, which Excel needs to open UTF-8 correctly.
+	InputEncoding  dencoding
+	OutputEncoding dencoding
+	WriteBOM       dbool
+	// InvalidUTF8 decides what happens to fields that are not valid UTF-8
+	// once decoded, by default they are passed through.
+	InvalidUTF8 dutf8
+	// DecompressInput inflates gzip, zlib and bzip2 input, found by its magic
+	// bytes, before it is parsed, it defaults to true. WriteGzip compresses
+	// Serialize output with gzip.
+	DecompressInput dbool
+	WriteGzip       dbool
+	// DoubleQuote treats two FieldOperators inside a quoted field as one
+	// literal FieldOperator, as RFC 4180 does.
+	DoubleQuote  dbool
+	ParseHeader  dbool
+	UseCache     dbool
+	StrictMap    dbool
+	SkipEmptyRow dbool
+	StripField   dbyte
+	// Serializers and Deserializers are keyed by type name, prefer
+	// TypeSerializers/TypeDeserializers or RegisterType for named types.
+	Serializers       dserial
+	Deserializers     ddeserial
+	TypeSerializers   dtserial
+	TypeDeserializers dtdeserial
+	// Converters are selected per field with the conv tag option, eg `csv:"sku,conv=upper"`.
+	Converters dconv
+	// IncludeColumns limits Serialize to the listed tags, in the listed order.
+	// ExcludeColumns drops the listed tags from Serialize.
+	IncludeColumns dstrings
+	ExcludeColumns dstrings
+	// FoldHeaderCase, CollapseHeader and HeaderNormalizer are applied to the
+	// header and to tags before they are matched. CollapseHeader replaces runs
+	// of whitespace and punctuation with a single underscore. Tags of two
+	// fields that normalise alike are a DSV_DUPLICATE_TAG_IN_STRUCT.
+	FoldHeaderCase   dbool
+	CollapseHeader   dbool
+	HeaderNormalizer dnorm
+	// DuplicateHeaders decides what happens when a header names the same
+	// column more than once, defaults to DuplicateLastWins.
+	DuplicateHeaders dduplicate
+	// StrictHeader makes Deserialize fail with DSV_HEADER_MISMATCH unless
+	// CheckHeader would report the header as OK.
+	StrictHeader dbool
+	// SkipRows drops leading records, then SkipUntil drops records until one
+	// matches. HeaderRow is the index of the header in what remains, anything
+	// before it is discarded. HeaderRows combines that many records into one
+	// header, eg a group row of "Q1" over "revenue" gives "Q1.revenue", blank
+	// group cells repeat the group to their left.
+	SkipRows        dint
+	SkipUntil       dpred
+	HeaderRow       dint
+	HeaderRows      dint
+	HeaderSeparator dbyte
+	// FooterRows drops trailing records and TrailerMatch drops any record it
+	// matches, both are available through DeserializeWithTrailer.
+	FooterRows   dint
+	TrailerMatch dpred
+	TrailerCount dtrailer
+	// CommentPrefix marks records to skip when it starts a line outside of a
+	// quoted field, OnComment receives the text of each skipped line.
+	CommentPrefix dbyte
+	OnComment     dcomment
+	// QuoteAll makes Serialize wrap every field in FieldOperator rather than
+	// only the fields that need it.
+	QuoteAll dbool
+	// EscapeSequences picks the sequences read after EscapeOperator as the
+	// byte they stand for, eg \n for a newline, and written in place of it.
+	EscapeSequences descapes
+	// NullString is the raw, unquoted and unescaped, cell standing for a
+	// missing value, eg `\N` for Postgres. Null cells leave the field at its
+	// zero value, nil for pointers, and nil pointers are written as NullString.
+	NullString dbyte
+	// FixedWidth reads and writes records whose fields sit at fixed byte
+	// positions given by the pos or width tag options, eg `csv:"acct,pos=1-10"`
+	// or `csv:"name,width=20,align=right,pad=0"`. Values are padded and
+	// truncated to fit, quoting and delimiters do not apply. A header row is
+	// skipped rather than matched as names may be cut short.
+	FixedWidth dbool
 }
 
 func DByte(s []byte) dbyte {
@@ -73,6 +260,14 @@ func DString(s string) dbyte {
 	return dbyte{ok: true, value: []byte(s)}
 }
 
+func DInt(i int) dint {
+	return dint{ok: true, value: i}
+}
+
+func DPredicate(f func([]string) bool) dpred {
+	return dpred{ok: true, value: f}
+}
+
 func DBool(b bool) dbool {
 	return dbool{ok: true, value: b}
 }
@@ -85,7 +280,19 @@ func DSerial(m map[string]func(interface{}) ([]byte, bool)) dserial {
 	return dserial{ok: true, value: m}
 }
 
-func ref(o interface{}) (map[string]reflect.StructField, reflect.Type, error) {
+func DStrings(s ...string) dstrings {
+	return dstrings{ok: true, value: s}
+}
+
+func DTDeserial(m map[reflect.Type]func(string, []byte) (interface{}, bool)) dtdeserial {
+	return dtdeserial{ok: true, value: m}
+}
+
+func DTSerial(m map[reflect.Type]func(interface{}) ([]byte, bool)) dtserial {
+	return dtserial{ok: true, value: m}
+}
+
+func ref(o interface{}) (map[string]fieldInfo, reflect.Type, error) {
 	t := reflect.TypeOf(o)
 	for t.Kind() == reflect.Ptr {
 		t = t.Elem()
@@ -99,15 +306,22 @@ func ref(o interface{}) (map[string]reflect.StructField, reflect.Type, error) {
 			return ref(t)
 		}
 	}
-	m := map[string]reflect.StructField{}
+	m := map[string]fieldInfo{}
+	seen := map[string]bool{}
 	for i := 0; i < t.NumField(); i++ {
-		field := t.Field(i)
-		tag := field.Tag.Get("csv")
+		field, e := parseField(t.Field(i))
+		if e != nil {
+			return nil, nil, e
+		}
+		tag := field.name
 		if tag == "" || tag == "-" {
 			continue
 		}
-		if _, exists := m[tag]; exists {
-			return nil, nil, dsvErr{err: fmt.Errorf("Tag '%s' appears multiple times in %s", tag, t.Name()), msg: DSV_DUPLICATE_TAG_IN_STRUCT.msg}
+		for _, a := range field.aliases {
+			if seen[a] {
+				return nil, nil, dsvErr{err: fmt.Errorf("Tag '%s' appears multiple times in %s", a, t.Name()), msg: DSV_DUPLICATE_TAG_IN_STRUCT.msg}
+			}
+			seen[a] = true
 		}
 		m[tag] = field
 	}
@@ -133,8 +347,36 @@ func NewDSV(opt DSVOpt) (dsvi, error) {
 		strictMap:      false,
 		skipEmptyRow:   true,
 		stripField:     []byte(" \r\n\t"),
-		deserializers:  DefaultDeserializers,
-		serializers:    DefaultSerializers,
+		deserializers:  map[reflect.Type]func(string, []byte) (interface{}, bool){},
+		serializers:    map[reflect.Type]func(interface{}) ([]byte, bool){},
+
+		deserializersByName: map[string]func(string, []byte) (interface{}, bool){},
+		serializersByName:   map[string]func(interface{}) ([]byte, bool){},
+		converters:          map[string]Converter{},
+		excludeColumns:      map[string]bool{},
+		headerRows:          1,
+		headerSeparator:     []byte("."),
+		decompressInput:     true,
+	}
+	for k, v := range DefaultTypeDeserializers {
+		di.deserializers[k] = v
+	}
+	for k, v := range DefaultTypeSerializers {
+		di.serializers[k] = v
+	}
+	di.addNamed(DefaultSerializers, DefaultDeserializers)
+	if opt.WhitespaceDelimiter.ok && opt.WhitespaceDelimiter.value {
+		di.whitespaceDelimiter = true
+		di.fieldDelimiter = []byte(" ")
+	}
+	if opt.FieldDelimiters.ok && len(opt.FieldDelimiters.value) > 0 {
+		di.fieldDelimiter = []byte(opt.FieldDelimiters.value[0])
+		for _, fd := range opt.FieldDelimiters.value {
+			if len(fd) == 0 {
+				return di, DSV_FIELD_DELIMITER_NZ
+			}
+			di.fieldDelimiters = append(di.fieldDelimiters, []byte(fd))
+		}
 	}
 	if opt.FieldDelimiter.ok {
 		di.fieldDelimiter = opt.FieldDelimiter.value
@@ -142,12 +384,70 @@ func NewDSV(opt DSVOpt) (dsvi, error) {
 	if opt.LineSeparator.ok {
 		di.lineSeparator = opt.LineSeparator.value
 	}
+	di.lineEnding = di.lineSeparator
+	if opt.LineEnding.ok {
+		di.lineEnding = opt.LineEnding.value
+	}
+	if opt.LegacyQuoting.ok {
+		di.legacyQuoting = opt.LegacyQuoting.value
+	}
+	if opt.MaxInputBytes.ok {
+		di.maxInputBytes = opt.MaxInputBytes.value
+	}
+	if opt.MaxFieldBytes.ok {
+		di.maxFieldBytes = opt.MaxFieldBytes.value
+	}
+	if opt.MaxFields.ok {
+		di.maxFields = opt.MaxFields.value
+	}
+	if opt.MaxRecords.ok {
+		di.maxRecords = opt.MaxRecords.value
+	}
+	if opt.InputEncoding.ok {
+		di.inputEncoding = opt.InputEncoding.value
+	}
+	if opt.OutputEncoding.ok {
+		di.outputEncoding = opt.OutputEncoding.value
+	}
+	if opt.WriteBOM.ok {
+		di.writeBOM = opt.WriteBOM.value
+	}
+	if opt.DecompressInput.ok {
+		di.decompressInput = opt.DecompressInput.value
+	}
+	if opt.WriteGzip.ok {
+		di.writeGzip = opt.WriteGzip.value
+	}
+	if opt.InvalidUTF8.ok {
+		di.invalidUTF8 = opt.InvalidUTF8.value
+	}
+	if opt.SanitizeFormulas.ok {
+		di.sanitizeFormulas = opt.SanitizeFormulas.value
+	}
+	if opt.FormulaColumns.ok {
+		di.formulaColumns = map[string]bool{}
+		for _, k := range opt.FormulaColumns.value {
+			di.formulaColumns[k] = true
+		}
+	}
+	if opt.StrictQuotes.ok {
+		di.strictQuotes = opt.StrictQuotes.value
+	}
+	if opt.LazyQuotes.ok {
+		di.lazyQuotes = opt.LazyQuotes.value
+	}
+	if opt.UniversalNewline.ok {
+		di.universalNewline = opt.UniversalNewline.value
+	}
 	if opt.FieldOperator.ok {
 		di.fieldOperator = opt.FieldOperator.value
 	}
 	if opt.EscapeOperator.ok {
 		di.escapeOperator = opt.EscapeOperator.value
 	}
+	if opt.DoubleQuote.ok {
+		di.doubleQuote = opt.DoubleQuote.value
+	}
 	if opt.ParseHeader.ok {
 		di.parseHeader = opt.ParseHeader.value
 	}
@@ -164,51 +464,186 @@ func NewDSV(opt DSVOpt) (dsvi, error) {
 		di.stripField = opt.StripField.value
 	}
 	if opt.Serializers.ok {
-		for k, v := range opt.Serializers.value {
-			di.serializers[k] = v //opt.Deserializers.value
-		}
+		di.addNamed(opt.Serializers.value, nil)
 	}
 	if opt.Deserializers.ok {
-		for k, v := range opt.Deserializers.value {
-			di.deserializers[k] = v //opt.Deserializers.value
+		di.addNamed(nil, opt.Deserializers.value)
+	}
+	if opt.TypeSerializers.ok {
+		for k, v := range opt.TypeSerializers.value {
+			di.serializers[k] = v
 		}
 	}
+	if opt.TypeDeserializers.ok {
+		for k, v := range opt.TypeDeserializers.value {
+			di.deserializers[k] = v
+		}
+	}
+	if opt.Converters.ok {
+		for k, v := range opt.Converters.value {
+			di.converters[k] = v
+		}
+	}
+	if opt.IncludeColumns.ok {
+		di.includeColumns = append([]string{}, opt.IncludeColumns.value...)
+	}
+	if opt.ExcludeColumns.ok {
+		for _, k := range opt.ExcludeColumns.value {
+			di.excludeColumns[k] = true
+		}
+	}
+	if opt.FoldHeaderCase.ok {
+		di.foldHeaderCase = opt.FoldHeaderCase.value
+	}
+	if opt.CollapseHeader.ok {
+		di.collapseHeader = opt.CollapseHeader.value
+	}
+	if opt.HeaderNormalizer.ok {
+		di.headerNormalizer = opt.HeaderNormalizer.value
+	}
+	if opt.DuplicateHeaders.ok {
+		di.duplicateHeaders = opt.DuplicateHeaders.value
+	}
+	if opt.StrictHeader.ok {
+		di.strictHeader = opt.StrictHeader.value
+	}
+	if opt.SkipRows.ok {
+		di.skipRows = opt.SkipRows.value
+	}
+	if opt.SkipUntil.ok {
+		di.skipUntil = opt.SkipUntil.value
+	}
+	if opt.HeaderRow.ok {
+		di.headerRow = opt.HeaderRow.value
+	}
+	if opt.HeaderRows.ok {
+		di.headerRows = opt.HeaderRows.value
+	}
+	if opt.HeaderSeparator.ok {
+		di.headerSeparator = opt.HeaderSeparator.value
+	}
+	if opt.FooterRows.ok {
+		di.footerRows = opt.FooterRows.value
+	}
+	if opt.TrailerMatch.ok {
+		di.trailerMatch = opt.TrailerMatch.value
+	}
+	if opt.TrailerCount.ok {
+		di.trailerCount = opt.TrailerCount.value
+	}
+	if opt.CommentPrefix.ok {
+		di.commentPrefix = opt.CommentPrefix.value
+	}
+	if opt.OnComment.ok {
+		di.onComment = opt.OnComment.value
+	}
+	if opt.QuoteAll.ok {
+		di.quoteAll = opt.QuoteAll.value
+	}
+	if opt.EscapeSequences.ok {
+		di.escapeSequences = opt.EscapeSequences.value
+	}
+	if opt.FixedWidth.ok {
+		di.fixedWidth = opt.FixedWidth.value
+	}
+	if opt.NullString.ok {
+		di.nullString = opt.NullString.value
+		di.hasNull = true
+	}
 
 	di.lslen = len(di.lineSeparator)
 	di.eolen = len(di.escapeOperator)
 	di.folen = len(di.fieldOperator)
 	di.fdlen = len(di.fieldDelimiter)
+	di.cplen = len(di.commentPrefix)
 
 	if di.fdlen == 0 {
 		return di, DSV_FIELD_DELIMITER_NZ
 	}
+	if di.fieldDelimiters == nil {
+		di.fieldDelimiters = [][]byte{di.fieldDelimiter}
+	}
+	sort.SliceStable(di.fieldDelimiters, func(i, j int) bool {
+		return len(di.fieldDelimiters[i]) > len(di.fieldDelimiters[j])
+	})
 	if di.lslen == 0 {
 		return di, DSV_LINE_SEPARATOR_NZ
 	}
+	if di.headerRows < 1 {
+		return di, DSV_HEADER_ROWS_NZ
+	}
+	if di.skipRows < 0 || di.headerRow < 0 || di.footerRows < 0 {
+		return di, DSV_NEGATIVE_ROW_OPTION
+	}
 
-	di.escapedDelimiter = append(di.escapeOperator, di.fieldDelimiter...)
-	di.escapedOperator = append(di.escapeOperator, di.fieldOperator...)
-	di.escapedSeparator = append(di.escapeOperator, di.lineSeparator...)
-	di.escdlen = di.eolen + di.fdlen
+	di.escapedOperator = append(append([]byte{}, di.escapeOperator...), di.fieldOperator...)
+	di.doubledOperator = append(append([]byte{}, di.fieldOperator...), di.fieldOperator...)
+	di.escapedEscape = append(append([]byte{}, di.escapeOperator...), di.escapeOperator...)
 	di.escolen = di.eolen + di.folen
-	di.escslen = di.eolen + di.lslen
 
 	return di, nil
 }
 
+// NormalizeString trims StripField from a raw field and removes its quotes
+// and escapes. The content of a quoted field is kept as written, only escaped
+// FieldOperators and EscapeOperators are unescaped within it.
 func (d dsvi) NormalizeString(s []byte) []byte {
+	if d.legacyQuoting {
+		return d.normalizeLegacy(s)
+	}
+	if len(d.stripField) != 0 {
+		s = bytes.Trim(s, string(d.stripField))
+	}
+	sl := len(s)
+	if d.folen > 0 && sl >= d.folen*2 && bytes.HasPrefix(s, d.fieldOperator) && bytes.HasSuffix(s, d.fieldOperator) {
+		s = s[d.folen : sl-d.folen]
+		if d.doubleQuote {
+			s = bytes.ReplaceAll(s, d.doubledOperator, d.fieldOperator)
+		}
+		return d.unescape(s, true)
+	}
+	return d.unescape(s, false)
+}
+
+// normalizeLegacy is NormalizeString with LegacyQuoting, an empty quoted
+// field keeps its quotes and escapes are removed inside quoted fields.
+func (d dsvi) normalizeLegacy(s []byte) []byte {
 	if len(d.stripField) != 0 {
 		s = bytes.Trim(s, string(d.stripField))
 	}
 	sl := len(s)
-	if sl > d.folen*2 && bytes.Compare(s[0:d.folen], d.fieldOperator) == 0 && bytes.Compare(s[sl-d.folen:], d.fieldOperator) == 0 {
+	if (sl > d.folen*2 || (d.doubleQuote && d.folen > 0 && sl == d.folen*2)) && bytes.Compare(s[0:d.folen], d.fieldOperator) == 0 && bytes.Compare(s[sl-d.folen:], d.fieldOperator) == 0 {
 		s = s[d.folen : sl-d.folen]
+		if d.doubleQuote {
+			s = bytes.ReplaceAll(s, d.doubledOperator, d.fieldOperator)
+		}
+	}
+	return d.unescape(s, false)
+}
+
+// unescape removes escape operators, within a quoted field only those before
+// a FieldOperator, an EscapeOperator or an EscapeSequences sequence are removed.
+func (d dsvi) unescape(s []byte, quoted bool) []byte {
+	if d.eolen == 0 {
+		return s
 	}
-	sl = len(s)
+	sl := len(s)
 	for i := 0; i < sl; i++ {
 		if sl > i+d.eolen && bytes.Compare(s[i:i+d.eolen], d.escapeOperator) == 0 {
+			next := s[i+d.eolen:]
+			c, n, seq := d.escapeSequences.read(next)
+			if quoted && !bytes.HasPrefix(next, d.escapeOperator) && !(d.folen > 0 && bytes.HasPrefix(next, d.fieldOperator)) && !seq {
+				i += d.eolen - 1
+				continue
+			}
+			if seq {
+				s = append(append(s[0:i], c), s[i+d.eolen+n:]...)
+				sl -= d.eolen + n - 1
+				continue
+			}
+			// drop the escape and step over the byte it escapes, so an
+			// escaped escape operator is kept as a literal
 			s = append(s[0:i], s[i+d.eolen:]...)
-			i -= d.eolen
 			sl -= d.eolen
 		}
 	}
@@ -216,58 +651,342 @@ func (d dsvi) NormalizeString(s []byte) []byte {
 }
 
 func (d dsvi) DeserializeMapIndex(s string) (map[int][]string, error) {
+	b, e := d.input([]byte(s))
+	if e != nil {
+		return map[int][]string{}, e
+	}
+	m, _, e := d.parse(string(b))
+	return m, e
+}
+
+// parse splits s into records and normalised fields, nulls holds the
+// {record, field} positions of cells matching NullString.
+func (d dsvi) parse(s string) (map[int][]string, map[[2]int]bool, error) {
 	m := map[int][]string{}
+	nulls := map[[2]int]bool{}
 
 	rmap := 0
 	inqt := false
 	l := 0
 	slen := len(s)
-	ed := string(d.escapedDelimiter)
-	es := string(d.escapedSeparator)
 	eo := string(d.escapedOperator)
+	ee := string(d.escapedEscape)
 	fo := string(d.fieldOperator)
-	fd := string(d.fieldDelimiter)
-	ls := string(d.lineSeparator)
+	cp := string(d.commentPrefix)
+	dq := string(d.doubledOperator)
+	if d.maxInputBytes > 0 && slen > d.maxInputBytes {
+		return m, nulls, DSV_INPUT_TOO_LARGE.enhance(fmt.Errorf("input is %d bytes, the limit is %d", slen, d.maxInputBytes))
+	}
+	emit := func(end int) error {
+		raw := s[l:end]
+		if d.maxFields > 0 && len(m[rmap]) >= d.maxFields {
+			line, _ := d.position(s, l)
+			return DSV_TOO_MANY_FIELDS.enhance(fmt.Errorf("line %d has more than %d fields", line, d.maxFields))
+		}
+		if d.whitespaceDelimiter {
+			raw = strings.TrimRight(raw, " \t")
+		}
+		if d.hasNull && d.isNull(raw) {
+			nulls[[2]int{rmap, len(m[rmap])}] = true
+			m[rmap] = append(m[rmap], "")
+			return nil
+		}
+		v, err := d.validUTF8(s, l, string(d.NormalizeString([]byte(raw))))
+		if err != nil {
+			return err
+		}
+		m[rmap] = append(m[rmap], v)
+		return nil
+	}
 	ol := l
-	for i := 0; i < slen; i++ {
-		if d.escdlen > 0 && slen > i+d.escdlen && s[i:i+d.escdlen] == ed {
-			i += d.escdlen - 1
-		} else if d.escslen > d.lslen && slen > i+d.escslen && s[i:i+d.escslen] == es {
-			i += d.escslen - 1
-		} else if d.escolen > d.folen && slen > i+d.escolen && s[i:i+d.escolen] == eo {
-			i += d.escolen - 1
-		} else if d.folen > 0 && slen > i+d.folen && s[i:i+d.folen] == fo {
-			inqt = !inqt
-		} else if !inqt && s[i:i+d.fdlen] == fd {
-			ss := d.NormalizeString([]byte(s[l:i]))
-			m[rmap] = append(m[rmap], string(ss))
-			i += d.fdlen - 1
-			l = i + 1
-		} else if !inqt && s[i:i+d.lslen] == ls {
-			ss := d.NormalizeString([]byte(s[l:i]))
-			m[rmap] = append(m[rmap], string(ss))
-			if ol <= i-d.lslen || !d.skipEmptyRow {
-				rmap++
-				m[rmap] = []string{}
-			} else {
-				m[rmap] = []string{}
+	qstart, literal := 0, -1
+	// set when the input ends in a comment with no separator after it
+	commentEnd := false
+	for start := 0; ; {
+		for i := start; i < slen; i++ {
+			if d.maxFieldBytes > 0 && i-l > d.maxFieldBytes {
+				line, col := d.position(s, l)
+				return m, nulls, DSV_FIELD_TOO_LARGE.enhance(fmt.Errorf("field at line %d, column %d is over %d bytes", line, col, d.maxFieldBytes))
+			}
+			if d.cplen > 0 && !inqt && i == ol && strings.HasPrefix(s[i:], cp) {
+				e, n := d.nextSeparator(s, i)
+				if d.onComment != nil {
+					d.onComment(s[i+d.cplen : e])
+				}
+				commentEnd = n == 0
+				i = e + n - 1
+				l = i + 1
+				ol = l
+				continue
 			}
-			i += d.lslen - 1
-			l = i + 1
-			ol = l
+			if d.doubleQuote && inqt && d.folen > 0 && slen >= i+d.folen*2 && s[i:i+d.folen*2] == dq {
+				i += d.folen*2 - 1
+			} else if d.eolen > 0 && slen > i+d.eolen*2 && s[i:i+d.eolen*2] == ee {
+				i += d.eolen*2 - 1
+			} else if n := d.escapedDelimiterAt(s, i); n > 0 {
+				i += n - 1
+			} else if n := d.escapedSeparatorAt(s, i); n > 0 {
+				i += n - 1
+			} else if d.escolen > d.folen && slen > i+d.escolen && s[i:i+d.escolen] == eo {
+				i += d.escolen - 1
+			} else if d.folen > 0 && strings.HasPrefix(s[i:], fo) && (!d.lazyQuotes || d.quoteAt(s, i, l, inqt)) && i != literal {
+				if d.strictQuotes && !d.lazyQuotes && !d.quoteAt(s, i, l, inqt) {
+					line, col := d.position(s, i)
+					return m, nulls, DSV_MISPLACED_QUOTE.enhance(fmt.Errorf("quote at line %d, column %d does not start or end a field", line, col))
+				}
+				inqt = !inqt
+				if inqt {
+					qstart = i
+				}
+				i += d.folen - 1
+			} else if n := d.delimiterAt(s, i); !inqt && n > 0 {
+				switch {
+				case d.whitespaceDelimiter && i == ol:
+					// leading whitespace
+					l = i + n
+				case d.whitespaceDelimiter && (i+n == slen || d.separatorAt(s, i+n) > 0):
+					// trailing whitespace, trimmed when the field is emitted
+				default:
+					if err := emit(i); err != nil {
+						return m, nulls, err
+					}
+					l = i + n
+				}
+				i += n - 1
+			} else if n := d.separatorAt(s, i); !inqt && n > 0 {
+				if err := emit(i); err != nil {
+					return m, nulls, err
+				}
+				if ol < i || !d.skipEmptyRow {
+					rmap++
+					m[rmap] = []string{}
+					if d.maxRecords > 0 && rmap > d.maxRecords {
+						return m, nulls, DSV_TOO_MANY_RECORDS.enhance(fmt.Errorf("more than %d records", d.maxRecords))
+					}
+				} else {
+					m[rmap] = []string{}
+					delete(nulls, [2]int{rmap, 0})
+				}
+				i += n - 1
+				l = i + 1
+				ol = l
+			}
+		}
+		if !inqt {
+			break
 		}
+		if d.lazyQuotes {
+			// read the quote as a literal and carry on from there, the
+			// record then ends at the next line separator
+			literal, start, inqt = qstart, qstart, false
+			continue
+		}
+		if d.strictQuotes {
+			line, col := d.position(s, qstart)
+			return m, nulls, DSV_UNTERMINATED_QUOTE.enhance(fmt.Errorf("quote opened at line %d, column %d is never closed", line, col))
+		}
+		break
 	}
-	ss := d.NormalizeString([]byte(s[l:]))
-	if ol <= slen-d.lslen || !d.skipEmptyRow {
-		m[rmap] = append(m[rmap], string(ss))
+	if (ol < slen || !d.skipEmptyRow) && !commentEnd {
+		if d.maxFieldBytes > 0 && slen-l > d.maxFieldBytes {
+			line, col := d.position(s, l)
+			return m, nulls, DSV_FIELD_TOO_LARGE.enhance(fmt.Errorf("field at line %d, column %d is over %d bytes", line, col, d.maxFieldBytes))
+		}
+		if err := emit(slen); err != nil {
+			return m, nulls, err
+		}
 	}
 	if len(m[rmap]) == 0 {
 		delete(m, rmap)
+	} else if d.maxRecords > 0 && rmap >= d.maxRecords {
+		return m, nulls, DSV_TOO_MANY_RECORDS.enhance(fmt.Errorf("more than %d records", d.maxRecords))
+	}
+	return m, nulls, nil
+}
+
+// separatorAt returns the length of the line separator starting at s[i], or
+// zero when there is none. With UniversalNewline that is any of \r\n, \n or \r.
+func (d dsvi) separatorAt(s string, i int) int {
+	if !d.universalNewline {
+		if strings.HasPrefix(s[i:], string(d.lineSeparator)) {
+			return d.lslen
+		}
+		return 0
+	}
+	switch {
+	case strings.HasPrefix(s[i:], "\r\n"):
+		return 2
+	case i < len(s) && (s[i] == '\r' || s[i] == '\n'):
+		return 1
+	}
+	return 0
+}
+
+// nextSeparator returns the index and length of the first line separator at
+// or after s[i], or len(s) and zero when there is none.
+func (d dsvi) nextSeparator(s string, i int) (int, int) {
+	for ; i < len(s); i++ {
+		if n := d.separatorAt(s, i); n > 0 {
+			return i, n
+		}
+	}
+	return len(s), 0
+}
+
+// escapedSeparatorAt returns the length of an escaped line separator starting
+// at s[i], or zero when there is none.
+func (d dsvi) escapedSeparatorAt(s string, i int) int {
+	if d.eolen == 0 || !strings.HasPrefix(s[i:], string(d.escapeOperator)) {
+		return 0
+	}
+	n := d.separatorAt(s, i+d.eolen)
+	if n == 0 || len(s) <= i+d.eolen+n {
+		return 0
+	}
+	return d.eolen + n
+}
+
+// quoteAt reports whether the FieldOperator at s[i] may open or close a
+// quoted field with LazyQuotes or StrictQuotes. A quote only opens at the
+// start of a field, l being where the field starts, and only closes before a
+// delimiter, a line separator or the end of s.
+func (d dsvi) quoteAt(s string, i, l int, inqt bool) bool {
+	if !inqt {
+		return strings.Trim(s[l:i], string(d.stripField)) == ""
+	}
+	j := i + d.folen
+	for j < len(s) && strings.IndexByte(string(d.stripField), s[j]) >= 0 && d.separatorAt(s, j) == 0 {
+		j++
+	}
+	return j == len(s) || d.delimiterAt(s, j) > 0 || d.separatorAt(s, j) > 0
+}
+
+// position returns the 1-based line and column of s[i].
+func (d dsvi) position(s string, i int) (int, int) {
+	line, from := 1, 0
+	for {
+		e, n := d.nextSeparator(s, from)
+		if n == 0 || e+n > i {
+			return line, i - from + 1
+		}
+		line++
+		from = e + n
 	}
-	return m, nil
+}
+
+// delimiterAt returns the length of the field delimiter starting at s[i], or
+// zero when there is none.
+func (d dsvi) delimiterAt(s string, i int) int {
+	if d.whitespaceDelimiter {
+		n := 0
+		for i+n < len(s) && (s[i+n] == ' ' || s[i+n] == '\t') && d.separatorAt(s, i+n) == 0 {
+			n++
+		}
+		return n
+	}
+	for _, fd := range d.fieldDelimiters {
+		if strings.HasPrefix(s[i:], string(fd)) {
+			return len(fd)
+		}
+	}
+	return 0
+}
+
+// escapedDelimiterAt returns the length of an escaped field delimiter starting
+// at s[i], only a single space or tab is escaped with WhitespaceDelimiter.
+func (d dsvi) escapedDelimiterAt(s string, i int) int {
+	if d.eolen == 0 || !strings.HasPrefix(s[i:], string(d.escapeOperator)) {
+		return 0
+	}
+	n := d.delimiterAt(s, i+d.eolen)
+	if n > 1 && d.whitespaceDelimiter {
+		n = 1
+	}
+	if n == 0 || len(s) <= i+d.eolen+n {
+		return 0
+	}
+	return d.eolen + n
+}
+
+// records returns the header record (nil without ParseHeader) and the
+// indexes of the data records in lineMap after any preamble is skipped.
+func (d dsvi) records(lineMap map[int][]string) ([]string, []int) {
+	rows := []int{}
+	for i := 0; i < len(lineMap); i++ {
+		if d.skipEmptyRow && len(lineMap[i]) == 0 {
+			continue
+		}
+		rows = append(rows, i)
+	}
+	if d.skipRows < len(rows) {
+		rows = rows[d.skipRows:]
+	} else {
+		rows = rows[len(rows):]
+	}
+	if d.skipUntil != nil {
+		for len(rows) > 0 && !d.skipUntil(lineMap[rows[0]]) {
+			rows = rows[1:]
+		}
+	}
+	if !d.parseHeader {
+		return nil, rows
+	}
+	end := d.headerRow + d.headerRows
+	if end > len(rows) {
+		return nil, rows[len(rows):]
+	}
+	if d.headerRows == 1 {
+		return lineMap[rows[d.headerRow]], rows[end:]
+	}
+	hrs := [][]string{}
+	for _, i := range rows[d.headerRow:end] {
+		hrs = append(hrs, lineMap[i])
+	}
+	return d.combineHeader(hrs), rows[end:]
+}
+
+// combineHeader joins multi-row headers into composite names, blank cells in
+// group rows take the value to their left as spreadsheets merge cells that way.
+func (d dsvi) combineHeader(hrs [][]string) []string {
+	n := 0
+	for _, hr := range hrs {
+		if len(hr) > n {
+			n = len(hr)
+		}
+	}
+	header := make([]string, n)
+	for r, hr := range hrs {
+		last := ""
+		for j := 0; j < n; j++ {
+			c := ""
+			if j < len(hr) {
+				c = hr[j]
+			}
+			if c == "" && r < len(hrs)-1 {
+				c = last
+			}
+			last = c
+			if c == "" {
+				continue
+			}
+			if header[j] != "" {
+				header[j] += string(d.headerSeparator)
+			}
+			header[j] += c
+		}
+	}
+	return header
 }
 
 func (d dsvi) Deserialize(s []byte, tgt interface{}) error {
+	return d.deserialize(s, tgt, nil)
+}
+
+func (d dsvi) deserialize(s []byte, tgt interface{}, trailer *[][]string) error {
+	s, err := d.input(s)
+	if err != nil {
+		return err
+	}
 	rs := reflect.ValueOf(tgt)
 	if rs.Kind() != reflect.Ptr {
 		return DSV_INVALID_TARGET_NOT_PTR
@@ -276,71 +995,124 @@ func (d dsvi) Deserialize(s []byte, tgt interface{}) error {
 	if rs.Kind() != reflect.Slice {
 		return DSV_INVALID_TARGET_NOT_SLICE.enhance(fmt.Errorf("got:%s", rs.Kind().String()))
 	}
+	if rs.Type().Elem().Kind() == reflect.Map {
+		if d.fixedWidth {
+			return DSV_FIXED_WIDTH_TAG.enhance(fmt.Errorf("FixedWidth needs a struct target, got:%s", rs.Type().Elem()))
+		}
+		lineMap, nulls, err := d.parse(string(s))
+		if err != nil {
+			return err
+		}
+		header, rows := d.records(lineMap)
+		if rows, err = d.splitTrailer(lineMap, rows, trailer); err != nil {
+			return err
+		}
+		return d.deserializeMaps(rs, lineMap, nulls, header, rows)
+	}
 	fmap, typ, e := ref(tgt)
 	if e != nil {
 		return e
 	}
 
-	lineMap, err := d.DeserializeMapIndex(string(s))
-	if err != nil {
+	var lineMap map[int][]string
+	var nulls map[[2]int]bool
+	var spans []fixedSpan
+	if d.fixedWidth {
+		if spans, err = fixedSpans(fmap); err != nil {
+			return err
+		}
+		if lineMap, err = d.parseFixed(string(s), spans); err != nil {
+			return err
+		}
+	} else if lineMap, nulls, err = d.parse(string(s)); err != nil {
 		return err
 	}
-	lineCount := len(lineMap)
-
-	offs := 0
-	if d.parseHeader {
-		offs = -1
+	header, rows := d.records(lineMap)
+	if rows, err = d.splitTrailer(lineMap, rows, trailer); err != nil {
+		return err
 	}
-	if lineCount+offs <= 0 {
+	if d.fixedWidth {
+		for j := range header {
+			header[j] = strings.TrimSpace(header[j])
+		}
+	}
+	if d.parseHeader && d.strictHeader {
+		r, e := d.checkHeader(fmap, header)
+		if e != nil {
+			return e
+		}
+		if !r.OK() {
+			return DSV_HEADER_MISMATCH.enhance(fmt.Errorf("%s", r))
+		}
+	}
+	if len(rows) == 0 && len(header) == 0 {
 		return nil
 	}
-	iln := len(lineMap[0])
-	rs.Set(reflect.MakeSlice(reflect.SliceOf(typ), lineCount+offs, lineCount+offs))
+	var cols []fieldInfo
+	if d.fixedWidth {
+		// positions are fixed, a header may hold names truncated to fit
+		for _, sp := range spans {
+			cols = append(cols, sp.fi)
+		}
+	} else if d.parseHeader {
+		cols, err = d.headerColumns(fmap, header)
+	} else {
+		cols, err = positionColumns(fmap)
+	}
+	if err != nil {
+		return err
+	}
+	// a header without records still has to carry the required columns
+	if len(rows) == 0 {
+		return nil
+	}
+	iln := len(header)
+	if !d.parseHeader {
+		iln = len(lineMap[rows[0]])
+	}
+	rs.Set(reflect.MakeSlice(reflect.SliceOf(typ), len(rows), len(rows)))
 	rowIndex := 0
-	for i := 0 + (-1 * offs); i < lineCount; i++ {
+	for _, i := range rows {
 		ln := lineMap[i]
-		if d.skipEmptyRow && len(ln) == 0 {
-			continue
-		}
 		if len(ln) != iln && d.strictMap {
 			return DSV_FIELD_NUM_MISMATCH.enhance(fmt.Errorf("StrictMap requires all rows have same number of fields, expected=%d,got=%d", iln, len(ln)))
 		}
 		fp := reflect.New(typ)
 		fv := fp.Elem()
+		filled := map[string]bool{}
 		for j, r := range ln {
-			var fs reflect.Value
-			if d.parseHeader {
-				if j >= len(lineMap[0]) {
-					break
+			if j >= len(cols) {
+				break
+			}
+			fi := cols[j]
+			fs := fv.FieldByName(fi.Name)
+			if fs.IsValid() && fs.CanSet() {
+				if nulls[[2]int{i, j}] || (r == "" && (fi.hasDef || fi.required)) {
+					continue
 				}
-				fs = fv.FieldByName(fmap[lineMap[0][j]].Name)
-			} else {
-				if j >= fv.NumField() {
-					break
+				if fi.collect {
+					setValue(fs, reflect.Append(fs, reflect.ValueOf(r).Convert(fs.Type().Elem())).Interface())
+				} else if perr := d.setField(fs, fi, r); perr != nil {
+					return perr
 				}
-				fs = fv.Field(j)
+				filled[fi.Name] = true
 			}
-			if fs.IsValid() && fs.CanSet() {
-				var perr error = nil
-				func() {
-					defer func() {
-						if r := recover(); r != nil {
-							perr = DSV_DESERIALIZE_ERROR.enhance(fmt.Errorf("%v", r))
-						}
-					}()
-					ty := fs.Type().String()
-					if f, okgo := d.deserializers[ty]; okgo {
-						v, _ := f(r, []byte(r))
-						fs.Set(reflect.ValueOf(v))
-					} else {
-						fs.Set(reflect.ValueOf(r))
-					}
-				}()
-				if perr != nil {
+		}
+		for k, fi := range fmap {
+			if filled[fi.Name] {
+				continue
+			}
+			if fi.hasDef {
+				if perr := d.setField(fv.FieldByName(fi.Name), fi, fi.def); perr != nil {
 					return perr
 				}
+			} else if fi.required {
+				return DSV_REQUIRED_FIELD_MISSING.enhance(fmt.Errorf("record %d: column %q is empty", rowIndex+1, k))
 			}
 		}
+		if e := afterDecode(fv, rowIndex+1); e != nil {
+			return e
+		}
 		rs.Index(rowIndex).Set(fv)
 		rowIndex++
 	}
@@ -348,39 +1120,155 @@ func (d dsvi) Deserialize(s []byte, tgt interface{}) error {
 	return nil
 }
 
-func (d dsvi) serializeIfc(src reflect.Value, fields []string) ([]byte, error) {
+func (d dsvi) setField(fs reflect.Value, fi fieldInfo, r string) (perr error) {
+	defer func() {
+		if r := recover(); r != nil {
+			perr = DSV_DESERIALIZE_ERROR.enhance(fmt.Errorf("%v", r))
+		}
+	}()
+	f, e := d.fieldDeserializer(fi, fs.Type())
+	if e != nil {
+		return e
+	}
+	if f == nil && fs.Kind() == reflect.Ptr {
+		// without a NullString an empty cell is the only way to say nil
+		if r == "" && !d.hasNull {
+			fs.Set(reflect.Zero(fs.Type()))
+			return nil
+		}
+		p := reflect.New(fs.Type().Elem())
+		if e := d.setField(p.Elem(), fieldInfo{StructField: fi.StructField}, r); e != nil {
+			return e
+		}
+		fs.Set(p)
+		return nil
+	}
+	if f != nil {
+		v, _ := f(r, []byte(r))
+		setValue(fs, v)
+	} else {
+		setValue(fs, r)
+	}
+	return nil
+}
+
+// fieldBytes serializes the field fi of src, null is set when the value is
+// a nil pointer and NullString was returned.
+func (d dsvi) fieldBytes(src reflect.Value, fi fieldInfo) ([]byte, bool, error) {
+	fv := src.FieldByName(fi.Name)
+	if !fv.IsValid() || (fi.omitEmpty && fv.IsZero()) {
+		return []byte{}, false, nil
+	}
+	if fi.conv == "" {
+		if fv = d.deref(fv); !fv.IsValid() {
+			return d.nullString, true, nil
+		}
+	}
+	f, ty, e := d.fieldSerializer(fi, fv.Type())
+	if e != nil {
+		return nil, false, e
+	}
+	v, _ := f(fv.Convert(ty).Interface())
+	if d.sanitizeFormulas && !d.formulaColumns[fi.name] && numericClass(fv.Kind()) == 0 {
+		v = SanitizeFormula(v)
+	}
+	return v, false, nil
+}
+
+func (d dsvi) serializeIfc(src reflect.Value, fields []fieldInfo) ([]byte, error) {
 	bs := []byte{}
-	for _, fidx := range fields {
-		fv := src.FieldByName(fidx)
-		ty := fv.Type().String()
-		if f, okgo := d.serializers[ty]; okgo {
-			v, _ := f(fv.Interface())
-			bs = append(append(bs, v...), d.fieldDelimiter...)
-		} else {
-			return bs, DSV_SERIALIZER_MISSING.enhance(fmt.Errorf("Unable to find handler for type: %s", ty))
+	for _, fi := range fields {
+		v, null, e := d.fieldBytes(src, fi)
+		if e != nil {
+			return bs, e
+		}
+		if !null {
+			v = d.escapeField(v)
 		}
+		bs = append(append(bs, v...), d.fieldDelimiter...)
 	}
 	if len(bs) > 0 {
-		bs = append(bs[:len(bs)-d.fdlen], d.lineSeparator...)
+		bs = append(bs[:len(bs)-d.fdlen], d.lineEnding...)
 	}
 	return bs, nil
 }
 
+// serializeColumns applies the include and exclude lists to fmap. Without a
+// header columns are written by position and left out columns become empty.
+func (d dsvi) serializeColumns(fmap map[string]fieldInfo) ([]fieldInfo, error) {
+	bks := []fieldInfo{}
+	if !d.parseHeader {
+		cols, e := positionColumns(fmap)
+		if e != nil {
+			return bks, e
+		}
+		include := map[string]bool{}
+		for _, k := range d.includeColumns {
+			if _, ok := fmap[k]; !ok {
+				return bks, DSV_COLUMN_NOT_FOUND.enhance(fmt.Errorf("IncludeColumns has unknown column %q", k))
+			}
+			include[k] = true
+		}
+		for _, fi := range cols {
+			if d.excludeColumns[fi.name] || (d.includeColumns != nil && !include[fi.name]) {
+				fi = fieldInfo{}
+			}
+			bks = append(bks, fi)
+		}
+		return bks, nil
+	}
+	if d.includeColumns != nil {
+		for _, k := range d.includeColumns {
+			v, ok := fmap[k]
+			if !ok {
+				return bks, DSV_COLUMN_NOT_FOUND.enhance(fmt.Errorf("IncludeColumns has unknown column %q", k))
+			}
+			if !d.excludeColumns[k] {
+				bks = append(bks, v)
+			}
+		}
+		return bks, nil
+	}
+	for _, v := range sortedFields(fmap) {
+		if !d.excludeColumns[v.name] {
+			bks = append(bks, v)
+		}
+	}
+	return bks, nil
+}
+
 func (d dsvi) Serialize(src interface{}) ([]byte, error) {
+	bs, e := d.serialize(src)
+	if e != nil {
+		return bs, e
+	}
+	return d.output(bs)
+}
+
+func (d dsvi) serialize(src interface{}) ([]byte, error) {
 	bs := []byte{}
 	fmap, _, e := ref(src)
 	if e != nil {
 		return bs, e
 	}
-	bks := []string{}
-	for k, v := range fmap {
+	if d.fixedWidth {
+		rs := reflect.ValueOf(src)
+		for rs.Kind() == reflect.Ptr {
+			rs = rs.Elem()
+		}
+		return d.serializeFixed(rs, fmap)
+	}
+	bks, e := d.serializeColumns(fmap)
+	if e != nil {
+		return bs, e
+	}
+	for _, v := range bks {
 		if d.parseHeader {
-			bs = append(append(bs, []byte(k)...), d.fieldDelimiter...)
+			bs = append(append(bs, d.escapeField([]byte(v.name))...), d.fieldDelimiter...)
 		}
-		bks = append(bks, v.Name)
 	}
 	if len(bs) > 0 {
-		bs = append(bs[:len(bs)-d.fdlen], d.lineSeparator...)
+		bs = append(bs[:len(bs)-d.fdlen], d.lineEnding...)
 	}
 
 	rs := reflect.ValueOf(src)
@@ -390,7 +1278,11 @@ func (d dsvi) Serialize(src interface{}) ([]byte, error) {
 		}
 	}
 	if rs.Kind() == reflect.Struct {
-		ds, e := d.serializeIfc(reflect.ValueOf(src), bks)
+		var e error
+		if rs, e = beforeEncode(rs, 1); e != nil {
+			return bs, e
+		}
+		ds, e := d.serializeIfc(rs, bks)
 		if e != nil {
 			return bs, e
 		}
@@ -401,6 +1293,10 @@ func (d dsvi) Serialize(src interface{}) ([]byte, error) {
 			for item.Kind() == reflect.Ptr {
 				item = item.Elem()
 			}
+			var e error
+			if item, e = beforeEncode(item, i+1); e != nil {
+				return bs, e
+			}
 			ds, e := d.serializeIfc(item, bks)
 			if e != nil {
 				return bs, e
@@ -411,8 +1307,8 @@ func (d dsvi) Serialize(src interface{}) ([]byte, error) {
 		fmt.Printf("unknown type: %v\n", rs.Kind())
 	}
 
-	if len(bs) > d.fdlen {
-		bs = bs[:len(bs)-d.fdlen]
+	if bytes.HasSuffix(bs, d.lineEnding) {
+		bs = bs[:len(bs)-len(d.lineEnding)]
 	}
 
 	return bs, nil
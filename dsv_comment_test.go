@@ -0,0 +1,83 @@
+package dsv_test
+
+import (
+	"errors"
+	"testing"
+
+	dsv "github.com/tony-o/dsv"
+)
+
+// TestDSV_Comment_Skip ensures comment lines are skipped and passed to the handler
+func TestDSV_Comment_Skip(t *testing.T) {
+	comments := []string{}
+	d := dsv.NewDSVMust(dsv.DSVOpt{
+		FieldDelimiter: dsv.DString("\t"),
+		CommentPrefix:  dsv.DString("//"),
+		OnComment: dsv.DCommentHandler(func(c string) {
+			comments = append(comments, c)
+		}),
+	})
+	data := "// generated by exporter\nid\tname\n1\t\"multi\n// not a comment\"\n// trailing note\n2\tb\n//eof"
+	xs := TagTestArray{}
+	if e := d.Deserialize([]byte(data), &xs); e != nil {
+		t.Logf("deserialize error: %v", e)
+		t.FailNow()
+	}
+	if len(xs) != 2 || xs[0].Name != "multi\n// not a comment" || xs[1].Id != 2 {
+		t.Logf("comment handling mismatch: got=%+v", xs)
+		t.FailNow()
+	}
+	expect := []string{" generated by exporter", " trailing note", "eof"}
+	if len(comments) != len(expect) {
+		t.Logf("comment count mismatch: expected=%q,got=%q", expect, comments)
+		t.FailNow()
+	}
+	for i := range expect {
+		if comments[i] != expect[i] {
+			t.Logf("comment %d mismatch: expected=%q,got=%q", i, expect[i], comments[i])
+			t.FailNow()
+		}
+	}
+}
+
+// TestDSV_Comment_LastLine ensures a comment on the last line adds no record when empty rows are kept
+func TestDSV_Comment_LastLine(t *testing.T) {
+	d := dsv.NewDSVMust(dsv.DSVOpt{
+		CommentPrefix: dsv.DString("#"),
+		SkipEmptyRow:  dsv.DBool(false),
+	})
+	for data, expect := range map[string]int{"a\n#c": 1, "a\n#c\n": 2, "#c": 0} {
+		m, e := d.DeserializeMapIndex(data)
+		if e != nil || len(m) != expect {
+			t.Logf("expected %d records from %q, got: %q (%v)", expect, data, m, e)
+			t.FailNow()
+		}
+	}
+}
+
+// TestDSV_Comment_RoundTrip ensures comments can be written back ahead of the data
+func TestDSV_Comment_RoundTrip(t *testing.T) {
+	comments := []string{}
+	d := dsv.NewDSVMust(dsv.DSVOpt{
+		CommentPrefix:  dsv.DString("#"),
+		IncludeColumns: dsv.DStrings("id", "name"),
+		OnComment: dsv.DCommentHandler(func(c string) {
+			comments = append(comments, c)
+		}),
+	})
+	data := "#version=2\n#owner=ops\nid,name\n1,a"
+	xs := TagTestArray{}
+	if e := d.Deserialize([]byte(data), &xs); e != nil {
+		t.Logf("deserialize error: %v", e)
+		t.FailNow()
+	}
+	bs, e := d.SerializeWithComments(xs, comments)
+	if e != nil || string(bs) != data {
+		t.Logf("round trip mismatch: err=%v,expected=%q,got=%q", e, data, string(bs))
+		t.FailNow()
+	}
+	if _, e := dsv.NewDSVMust(dsv.DSVOpt{}).SerializeWithComments(xs, comments); !errors.Is(e, dsv.DSV_COMMENT_PREFIX_NZ) {
+		t.Logf("expected comment prefix error, got: %v", e)
+		t.FailNow()
+	}
+}
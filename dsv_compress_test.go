@@ -0,0 +1,142 @@
+package dsv_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	dsv "github.com/tony-o/dsv"
+)
+
+const compressInput = "a,b\n1,2\n3,4"
+
+// compressBzip2 is compressInput written by bzip2, the standard library has no
+// bzip2 writer.
+var compressBzip2 = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0x7b, 0x56, 0x8e, 0x5f, 0x00, 0x00,
+	0x04, 0xd9, 0x00, 0x00, 0x10, 0x00, 0x04, 0x3c, 0x00, 0x30, 0x00, 0x20, 0x00, 0x31, 0x0c, 0x08,
+	0x21, 0xa3, 0x27, 0xa8, 0x37, 0x09, 0xc9, 0x0b, 0xc5, 0xdc, 0x91, 0x4e, 0x14, 0x24, 0x1e, 0xd5,
+	0xa3, 0x97, 0xc0,
+}
+
+func gzipBytes(s []byte) []byte {
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	w.Write(s)
+	w.Close()
+	return b.Bytes()
+}
+
+func zlibBytes(s []byte) []byte {
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+	w.Write(s)
+	w.Close()
+	return b.Bytes()
+}
+
+// TestDSV_Decompress ensures compressed input is found by its magic bytes and read as plain input
+func TestDSV_Decompress(t *testing.T) {
+	expected := []map[string]string{{"a": "1", "b": "2"}, {"a": "3", "b": "4"}}
+	tests := []struct {
+		Name  string
+		Input []byte
+	}{
+		{Name: "plain", Input: []byte(compressInput)},
+		{Name: "gzip", Input: gzipBytes([]byte(compressInput))},
+		{Name: "zlib", Input: zlibBytes([]byte(compressInput))},
+		{Name: "bzip2", Input: compressBzip2},
+	}
+	d := dsv.NewDSVMust(dsv.DSVOpt{})
+	for _, tst := range tests {
+		t.Run(tst.Name, func(t2 *testing.T) {
+			rs := []map[string]string{}
+			if e := d.Deserialize(tst.Input, &rs); e != nil {
+				t2.Logf("unexpected error: %v", e)
+				t2.FailNow()
+			}
+			if !reflect.DeepEqual(rs, expected) {
+				t2.Logf("expected %v, got: %v", expected, rs)
+				t2.FailNow()
+			}
+			rs = []map[string]string{}
+			if e := d.DeserializeReader(bytes.NewReader(tst.Input), &rs); e != nil {
+				t2.Logf("unexpected error from reader: %v", e)
+				t2.FailNow()
+			}
+			if !reflect.DeepEqual(rs, expected) {
+				t2.Logf("expected %v from reader, got: %v", expected, rs)
+				t2.FailNow()
+			}
+		})
+	}
+
+	rs := []map[string]string{}
+	if e := d.Deserialize([]byte("BZh9x,y\n1,2"), &rs); e != nil || !reflect.DeepEqual(rs, []map[string]string{{"BZh9x": "1", "y": "2"}}) {
+		t.Logf("expected plain input starting with the bzip2 magic to be read as is, got: %v (%v)", rs, e)
+		t.FailNow()
+	}
+
+	rs = []map[string]string{}
+	dsv.NewDSVMust(dsv.DSVOpt{DecompressInput: dsv.DBool(false)}).Deserialize(gzipBytes([]byte(compressInput)), &rs)
+	if reflect.DeepEqual(rs, expected) {
+		t.Logf("expected gzip input to be left compressed with DecompressInput false")
+		t.FailNow()
+	}
+}
+
+// TestDSV_DecompressLimit ensures MaxInputBytes bounds the decompressed size
+func TestDSV_DecompressLimit(t *testing.T) {
+	bomb := gzipBytes(bytes.Repeat([]byte("a,b\n"), 1<<18))
+	d := dsv.NewDSVMust(dsv.DSVOpt{MaxInputBytes: dsv.DInt(1 << 16)})
+	if len(bomb) > 1<<16 {
+		t.Logf("expected the compressed input to be under the limit, it is %d bytes", len(bomb))
+		t.FailNow()
+	}
+	if e := d.Deserialize(bomb, &[]map[string]string{}); !errors.Is(e, dsv.DSV_INPUT_TOO_LARGE) {
+		t.Logf("expected %v, got: %v", dsv.DSV_INPUT_TOO_LARGE, e)
+		t.FailNow()
+	}
+	if e := d.DeserializeReader(bytes.NewReader(zlibBytes(bytes.Repeat([]byte("a,b\n"), 1<<18))), &[]map[string]string{}); !errors.Is(e, dsv.DSV_INPUT_TOO_LARGE) {
+		t.Logf("expected %v from zlib, got: %v", dsv.DSV_INPUT_TOO_LARGE, e)
+		t.FailNow()
+	}
+	if e := d.Deserialize([]byte{0x1f, 0x8b, 0x00}, &[]map[string]string{}); !errors.Is(e, dsv.DSV_DECOMPRESS_ERROR) {
+		t.Logf("expected %v for a truncated gzip header, got: %v", dsv.DSV_DECOMPRESS_ERROR, e)
+		t.FailNow()
+	}
+}
+
+// TestDSV_WriteGzip ensures WriteGzip output is gzip and reads back
+func TestDSV_WriteGzip(t *testing.T) {
+	type row struct {
+		A string `csv:"a"`
+		B string `csv:"b"`
+	}
+	src := []row{{A: "1", B: "2"}, {A: "3", B: "4"}}
+	d := dsv.NewDSVMust(dsv.DSVOpt{WriteGzip: dsv.DBool(true)})
+	bs, e := d.Serialize(src)
+	if e != nil {
+		t.Logf("unexpected error: %v", e)
+		t.FailNow()
+	}
+	r, e := gzip.NewReader(bytes.NewReader(bs))
+	if e != nil {
+		t.Logf("expected gzip output, got: %v", e)
+		t.FailNow()
+	}
+	plain, _ := io.ReadAll(r)
+	if string(plain) != compressInput {
+		t.Logf("expected %q, got: %q", compressInput, plain)
+		t.FailNow()
+	}
+	rs := []row{}
+	if e := d.Deserialize(bs, &rs); e != nil || !reflect.DeepEqual(rs, src) {
+		t.Logf("expected %v, got: %v (%v)", src, rs, e)
+		t.FailNow()
+	}
+}
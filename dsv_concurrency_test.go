@@ -0,0 +1,108 @@
+package dsv_test
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+
+	dsv "github.com/tony-o/dsv"
+)
+
+// these tests are only meaningful when run with the race detector: go test -race ./...
+
+func doubleInt(s string, _ []byte) (interface{}, bool) {
+	i, e := strconv.Atoi(s)
+	if e != nil {
+		return -1, true
+	}
+	return i * 2, true
+}
+
+// TestDSV_Concurrency_IsolatedRegistries ensures custom (de)serializers do not leak between instances
+func TestDSV_Concurrency_IsolatedRegistries(t *testing.T) {
+	custom := dsv.NewDSVMust(dsv.DSVOpt{
+		Deserializers: dsv.DDeserial(map[string]func(string, []byte) (interface{}, bool){
+			"int": doubleInt,
+		}),
+	})
+	plain := dsv.NewDSVMust(dsv.DSVOpt{})
+
+	data := []byte("id,name\n5,name1")
+	a := TagTestArray{}
+	b := TagTestArray{}
+	if e := custom.Deserialize(data, &a); e != nil {
+		t.Logf("deserialize error: %v", e)
+		t.FailNow()
+	}
+	if e := plain.Deserialize(data, &b); e != nil {
+		t.Logf("deserialize error: %v", e)
+		t.FailNow()
+	}
+	if len(a) != 1 || a[0].Id != 10 {
+		t.Logf("custom deserializer not used: expected=10,got=%+v", a)
+		t.FailNow()
+	}
+	if len(b) != 1 || b[0].Id != 5 {
+		t.Logf("custom deserializer leaked into another instance: expected=5,got=%+v", b)
+		t.FailNow()
+	}
+}
+
+// TestDSV_Concurrency_SharedInstance exercises NewDSV, Deserialize and Serialize from many goroutines
+func TestDSV_Concurrency_SharedInstance(t *testing.T) {
+	shared := dsv.NewDSVMust(dsv.DSVOpt{})
+	data := []byte("id,name,email address\n1,name1,email1@xyz.com\n2,name2,email2@xyz.com")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			own, e := dsv.NewDSV(dsv.DSVOpt{
+				Deserializers: dsv.DDeserial(map[string]func(string, []byte) (interface{}, bool){
+					"int": doubleInt,
+				}),
+				Serializers: dsv.DSerial(map[string]func(interface{}) ([]byte, bool){
+					"string": func(i interface{}) ([]byte, bool) {
+						return []byte(fmt.Sprintf("<%s>", i)), true
+					},
+				}),
+			})
+			if e != nil {
+				errs <- e
+				return
+			}
+			var d = shared
+			if i%2 == 0 {
+				d = own
+			}
+			xs := TagTestArray{}
+			if e := d.Deserialize(data, &xs); e != nil {
+				errs <- e
+				return
+			}
+			if len(xs) != 2 {
+				errs <- fmt.Errorf("row count expected=2,got=%d", len(xs))
+				return
+			}
+			if _, e := d.Serialize(xs); e != nil {
+				errs <- e
+				return
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for e := range errs {
+		t.Logf("concurrent use failed: %v", e)
+		t.Fail()
+	}
+
+	xs := TagTestArray{}
+	if e := shared.Deserialize(data, &xs); e != nil || xs[0].Id != 1 {
+		t.Logf("shared instance was modified: err=%v,got=%+v", e, xs)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,88 @@
+package dsv_test
+
+import (
+	"reflect"
+	"testing"
+
+	dsv "github.com/tony-o/dsv"
+)
+
+// TestDSV_Delimiter_Alternatives ensures any listed delimiter splits fields while quotes and escapes still apply
+func TestDSV_Delimiter_Alternatives(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Dsvo   dsv.DSVOpt
+		Input  string
+		Expect map[int][]string
+	}{
+		{
+			Name:   "semicolon or comma",
+			Dsvo:   dsv.DSVOpt{FieldDelimiters: dsv.DStrings(";", ",")},
+			Input:  "a;b,\"c;d\",e\\,f\ng,h;i",
+			Expect: map[int][]string{0: {"a", "b", "c;d", "e,f"}, 1: {"g", "h", "i"}},
+		},
+		{
+			Name:   "longest first",
+			Dsvo:   dsv.DSVOpt{FieldDelimiters: dsv.DStrings(":", "::")},
+			Input:  "a::b:c",
+			Expect: map[int][]string{0: {"a", "b", "c"}},
+		},
+		{
+			Name:   "whitespace runs",
+			Dsvo:   dsv.DSVOpt{WhitespaceDelimiter: dsv.DBool(true)},
+			Input:  "  id   name\tscore  \n1  \"bob  smith\" \t 3.5\n2 al\\ x 4",
+			Expect: map[int][]string{0: {"id", "name", "score"}, 1: {"1", "bob  smith", "3.5"}, 2: {"2", "al x", "4"}},
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.Name, func(t2 *testing.T) {
+			d := dsv.NewDSVMust(tst.Dsvo)
+			m, e := d.DeserializeMapIndex(tst.Input)
+			if e != nil {
+				t2.Logf("deserialize error: %v", e)
+				t2.FailNow()
+			}
+			if !reflect.DeepEqual(m, tst.Expect) {
+				t2.Logf("mismatch: expected=%q,got=%q", tst.Expect, m)
+				t2.FailNow()
+			}
+		})
+	}
+}
+
+// TestDSV_Delimiter_WhitespaceRoundTrip ensures empty values and values containing whitespace are quoted when written
+func TestDSV_Delimiter_WhitespaceRoundTrip(t *testing.T) {
+	d := dsv.NewDSVMust(dsv.DSVOpt{WhitespaceDelimiter: dsv.DBool(true)})
+	src := TagTestArray{{Id: 1, Name: "bob smith", Email: "bob@xyz.com"}, {Id: 2, Name: "al", Email: "al@xyz.com"}, {Id: 3, Name: "", Email: "x@xyz.com"}, {Id: 4, Name: "", Email: ""}}
+	bs, e := d.Serialize(src)
+	if e != nil {
+		t.Logf("serialize error: %v", e)
+		t.FailNow()
+	}
+	xs := TagTestArray{}
+	if e := d.Deserialize(bs, &xs); e != nil || !reflect.DeepEqual(xs, src) {
+		t.Logf("round trip mismatch: err=%v,data=%q,got=%+v", e, string(bs), xs)
+		t.FailNow()
+	}
+}
+
+// TestDSV_Delimiter_WhitespaceEmpty ensures an empty middle column keeps the columns after it in place
+func TestDSV_Delimiter_WhitespaceEmpty(t *testing.T) {
+	type row struct {
+		A string `csv:"a"`
+		B string `csv:"b"`
+		C string `csv:"c"`
+	}
+	d := dsv.NewDSVMust(dsv.DSVOpt{WhitespaceDelimiter: dsv.DBool(true)})
+	src := []row{{A: "x", B: "", C: "z"}}
+	bs, e := d.Serialize(src)
+	if e != nil || string(bs) != "a b c\nx \"\" z" {
+		t.Logf("expected %q, got: %q (%v)", "a b c\nx \"\" z", bs, e)
+		t.FailNow()
+	}
+	rs := []row{}
+	if e := d.Deserialize(bs, &rs); e != nil || !reflect.DeepEqual(rs, src) {
+		t.Logf("round trip mismatch: err=%v,got=%+v", e, rs)
+		t.FailNow()
+	}
+}
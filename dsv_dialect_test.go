@@ -0,0 +1,144 @@
+package dsv_test
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	dsv "github.com/tony-o/dsv"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+type Dialect struct {
+	Id    int     `csv:"id"`
+	Text  string  `csv:"text"`
+	Note  *string `csv:"note"`
+	Score float64 `csv:"score"`
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+var dialectRows = []Dialect{
+	{Id: 1, Text: "plain", Note: strPtr("note"), Score: 1.5},
+	{Id: 2, Text: `comma, "quoted"`, Score: 0},
+	{Id: 3, Text: "tab\tand\nnewline", Note: strPtr(""), Score: -2.25},
+	{Id: 4, Text: ` back\slash \N`, Note: strPtr(`\N`), Score: 3},
+	{Id: 5, Text: "bs\b ff\f vt\v nul\x00 sub\x1a", Score: 0.5},
+}
+
+// TestDSV_Dialect_Golden ensures each preset writes its golden file and reads it back
+func TestDSV_Dialect_Golden(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Dsvo     dsv.DSVOpt
+		Nullable bool
+	}{
+		{Name: "rfc4180", Dsvo: dsv.RFC4180()},
+		{Name: "excel", Dsvo: dsv.Excel()},
+		{Name: "unix", Dsvo: dsv.Unix()},
+		{Name: "tsv", Dsvo: dsv.TSV()},
+		{Name: "postgres_text", Dsvo: dsv.PostgresText(), Nullable: true},
+		{Name: "postgres_csv", Dsvo: dsv.PostgresCSV(), Nullable: true},
+		{Name: "mysql", Dsvo: dsv.MySQL(), Nullable: true},
+		{Name: "ascii", Dsvo: dsv.ASCII()},
+	}
+	for _, tst := range tests {
+		t.Run(tst.Name, func(t2 *testing.T) {
+			d := dsv.NewDSVMust(tst.Dsvo)
+			bs, e := d.Serialize(dialectRows)
+			if e != nil {
+				t2.Logf("serialize error: %v", e)
+				t2.FailNow()
+			}
+			golden := filepath.Join("testdata", "dialect", tst.Name+".golden")
+			if *update {
+				if e := os.WriteFile(golden, bs, 0644); e != nil {
+					t2.Logf("unable to update %s: %v", golden, e)
+					t2.FailNow()
+				}
+			}
+			expect, e := os.ReadFile(golden)
+			if e != nil {
+				t2.Logf("unable to read %s: %v", golden, e)
+				t2.FailNow()
+			}
+			if string(bs) != string(expect) {
+				t2.Logf("serialize mismatch: expected=%q,got=%q", string(expect), string(bs))
+				t2.FailNow()
+			}
+
+			ds := []Dialect{}
+			if e := d.Deserialize(expect, &ds); e != nil {
+				t2.Logf("deserialize error: %v", e)
+				t2.FailNow()
+			}
+			want := append([]Dialect{}, dialectRows...)
+			if !tst.Nullable {
+				// without a null marker an empty note reads back as nil
+				want[2].Note = nil
+			}
+			if !reflect.DeepEqual(ds, want) {
+				t2.Logf("round trip mismatch: expected=%+v,got=%+v", want, ds)
+				t2.FailNow()
+			}
+		})
+	}
+}
+
+// TestDSV_Dialect_Read ensures each preset reads the input its format allows
+func TestDSV_Dialect_Read(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Dsvo   dsv.DSVOpt
+		Input  string
+		Expect string
+		Err    error
+	}{
+		{Name: "rfc4180 unterminated", Dsvo: dsv.RFC4180(), Input: "a,\"b\r\nc,d", Err: dsv.DSV_UNTERMINATED_QUOTE},
+		{Name: "excel bom", Dsvo: dsv.Excel(), Input: "\ufeffa,b", Expect: "a"},
+		{Name: "excel lf", Dsvo: dsv.Excel(), Input: "a\nb", Expect: "a"},
+		{Name: "excel stray quote", Dsvo: dsv.Excel(), Input: "5\" tall,b", Expect: "5\" tall"},
+		{Name: "tsv octal", Dsvo: dsv.TSV(), Input: `\101`, Expect: "101"},
+		{Name: "postgres octal", Dsvo: dsv.PostgresText(), Input: `\101\0`, Expect: "A\x00"},
+		{Name: "postgres hex", Dsvo: dsv.PostgresText(), Input: `\x41\x4a2`, Expect: "AJ2"},
+		{Name: "postgres z", Dsvo: dsv.PostgresText(), Input: `\Z`, Expect: "Z"},
+		{Name: "mysql z", Dsvo: dsv.MySQL(), Input: `\Z\0\b`, Expect: "\x1a\x00\b"},
+		{Name: "mysql octal", Dsvo: dsv.MySQL(), Input: `\101`, Expect: "101"},
+	}
+	for _, tst := range tests {
+		t.Run(tst.Name, func(t2 *testing.T) {
+			m, e := dsv.NewDSVMust(tst.Dsvo).DeserializeMapIndex(tst.Input)
+			if tst.Err != nil {
+				if !errors.Is(e, tst.Err) {
+					t2.Logf("expected %v, got: %v", tst.Err, e)
+					t2.FailNow()
+				}
+				return
+			}
+			if e != nil || len(m[0]) == 0 || m[0][0] != tst.Expect {
+				t2.Logf("expected %q, got: %q (%v)", tst.Expect, m, e)
+				t2.FailNow()
+			}
+		})
+	}
+}
+
+// TestDSV_Dialect_Null ensures NullString is told apart from the same text quoted or escaped
+func TestDSV_Dialect_Null(t *testing.T) {
+	ms := []map[string]string{}
+	d := dsv.NewDSVMust(dsv.PostgresCSV())
+	if e := d.Deserialize([]byte("a,,\"\""), &ms); e != nil {
+		t.Logf("deserialize error: %v", e)
+		t.FailNow()
+	}
+	if len(ms) != 1 || len(ms[0]) != 2 || ms[0]["0"] != "a" || ms[0]["2"] != "" {
+		t.Logf("null cell mismatch: got=%+v", ms)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,98 @@
+package dsv_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	dsv "github.com/tony-o/dsv"
+)
+
+// TestDSV_Encoding_Decode ensures each input encoding and byte order mark is read as UTF-8
+func TestDSV_Encoding_Decode(t *testing.T) {
+	expect := TagTestArray{{Id: 1, Name: "Zoë €5", Email: "zoë@xyz.com"}}
+	tests := []struct {
+		Name  string
+		Dsvo  dsv.DSVOpt
+		Input []byte
+	}{
+		{Name: "utf-8 bom", Dsvo: dsv.DSVOpt{}, Input: append([]byte{0xEF, 0xBB, 0xBF}, "id,name,email address\n1,Zoë €5,zoë@xyz.com"...)},
+		{Name: "utf-16le bom", Dsvo: dsv.DSVOpt{}, Input: []byte("\xff\xfei\x00d\x00,\x00n\x00a\x00m\x00e\x00,\x00e\x00m\x00a\x00i\x00l\x00 \x00a\x00d\x00d\x00r\x00e\x00s\x00s\x00\n\x001\x00,\x00Z\x00o\x00\xeb\x00 \x00\xac\x205\x00,\x00z\x00o\x00\xeb\x00@\x00x\x00y\x00z\x00.\x00c\x00o\x00m\x00")},
+		{Name: "utf-16be", Dsvo: dsv.DSVOpt{InputEncoding: dsv.DEncoding(dsv.EncodingUTF16BE)}, Input: []byte("\x00i\x00d\x00,\x00n\x00a\x00m\x00e\x00,\x00e\x00m\x00a\x00i\x00l\x00 \x00a\x00d\x00d\x00r\x00e\x00s\x00s\x00\n\x001\x00,\x00Z\x00o\x00\xeb\x00 \x20\xac\x005\x00,\x00z\x00o\x00\xeb\x00@\x00x\x00y\x00z\x00.\x00c\x00o\x00m")},
+		{Name: "cp1252", Dsvo: dsv.DSVOpt{InputEncoding: dsv.DEncoding(dsv.EncodingCP1252)}, Input: []byte("id,name,email address\n1,Zo\xeb \x805,zo\xeb@xyz.com")},
+	}
+	for _, tst := range tests {
+		t.Run(tst.Name, func(t2 *testing.T) {
+			xs := TagTestArray{}
+			if e := dsv.NewDSVMust(tst.Dsvo).Deserialize(tst.Input, &xs); e != nil || !reflect.DeepEqual(xs, expect) {
+				t2.Logf("decode mismatch: err=%v,expected=%+v,got=%+v", e, expect, xs)
+				t2.FailNow()
+			}
+		})
+	}
+	xs := TagTestArray{}
+	if e := dsv.NewDSVMust(dsv.DSVOpt{InputEncoding: dsv.DEncoding(dsv.EncodingLatin1)}).Deserialize([]byte("id,name\n1,\x80\xe9"), &xs); e != nil || len(xs) != 1 || xs[0].Name != "\u0080é" {
+		t.Logf("latin-1 mismatch: err=%v,got=%+v", e, xs)
+		t.FailNow()
+	}
+}
+
+// TestDSV_Encoding_Encode ensures output is encoded, optionally with a byte order mark
+func TestDSV_Encoding_Encode(t *testing.T) {
+	src := TagTestArray{{Id: 1, Name: "€"}}
+	opt := dsv.DSVOpt{IncludeColumns: dsv.DStrings("id", "name")}
+
+	opt.WriteBOM = dsv.DBool(true)
+	bs, e := dsv.NewDSVMust(opt).Serialize(src)
+	if e != nil || !bytes.Equal(bs, []byte("\xef\xbb\xbfid,name\n1,€")) {
+		t.Logf("utf-8 bom mismatch: err=%v,got=%q", e, bs)
+		t.FailNow()
+	}
+
+	opt.OutputEncoding = dsv.DEncoding(dsv.EncodingUTF16LE)
+	d := dsv.NewDSVMust(opt)
+	bs, e = d.Serialize(src)
+	if e != nil || !bytes.Equal(bs, []byte("\xff\xfei\x00d\x00,\x00n\x00a\x00m\x00e\x00\n\x001\x00,\x00\xac\x20")) {
+		t.Logf("utf-16le mismatch: err=%v,got=%q", e, bs)
+		t.FailNow()
+	}
+	xs := TagTestArray{}
+	if e := d.Deserialize(bs, &xs); e != nil || !reflect.DeepEqual(xs, src) {
+		t.Logf("utf-16le round trip mismatch: err=%v,got=%+v", e, xs)
+		t.FailNow()
+	}
+
+	opt = dsv.DSVOpt{IncludeColumns: dsv.DStrings("id", "name"), OutputEncoding: dsv.DEncoding(dsv.EncodingCP1252)}
+	bs, e = dsv.NewDSVMust(opt).Serialize(src)
+	if e != nil || !bytes.Equal(bs, []byte("id,name\n1,\x80")) {
+		t.Logf("cp1252 mismatch: err=%v,got=%q", e, bs)
+		t.FailNow()
+	}
+	opt.OutputEncoding = dsv.DEncoding(dsv.EncodingLatin1)
+	if _, e = dsv.NewDSVMust(opt).Serialize(src); !errors.Is(e, dsv.DSV_ENCODE_ERROR) {
+		t.Logf("expected encode error for latin-1, got: %v", e)
+		t.FailNow()
+	}
+}
+
+// TestDSV_Encoding_InvalidUTF8 ensures each invalid UTF-8 policy is applied to fields
+func TestDSV_Encoding_InvalidUTF8(t *testing.T) {
+	input := "id,name\n1,ok\n2,b\xffd\xfe"
+	xs := TagTestArray{}
+	if e := dsv.NewDSVMust(dsv.DSVOpt{}).Deserialize([]byte(input), &xs); e != nil || xs[1].Name != "b\xffd\xfe" {
+		t.Logf("pass through mismatch: err=%v,got=%+v", e, xs)
+		t.FailNow()
+	}
+	xs = TagTestArray{}
+	if e := dsv.NewDSVMust(dsv.DSVOpt{InvalidUTF8: dsv.DInvalidUTF8(dsv.InvalidUTF8Replace)}).Deserialize([]byte(input), &xs); e != nil || xs[1].Name != "b�d�" {
+		t.Logf("replace mismatch: err=%v,got=%+v", e, xs)
+		t.FailNow()
+	}
+	e := dsv.NewDSVMust(dsv.DSVOpt{InvalidUTF8: dsv.DInvalidUTF8(dsv.InvalidUTF8Error)}).Deserialize([]byte(input), &TagTestArray{})
+	if !errors.Is(e, dsv.DSV_INVALID_UTF8) || !strings.Contains(e.Error(), "0xff at line 3, column 4") {
+		t.Logf("expected invalid utf-8 error at line 3, column 4, got: %v", e)
+		t.FailNow()
+	}
+}
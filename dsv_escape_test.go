@@ -0,0 +1,45 @@
+package dsv_test
+
+import (
+	"reflect"
+	"testing"
+
+	dsv "github.com/tony-o/dsv"
+)
+
+type escapeRow struct {
+	Name  string `csv:"name"`
+	Note  string `csv:"note"`
+	Score *int   `csv:"score"`
+	Rank  int    `csv:"rank"`
+}
+
+// TestDSV_Serialize_Escaping ensures fields holding delimiters, quotes and newlines read back as written,
+// columns keep the struct order and pointers are written as the value they point at
+func TestDSV_Serialize_Escaping(t *testing.T) {
+	n := 7
+	src := []escapeRow{
+		{Name: "a,b", Note: "say \"hi\"", Score: &n, Rank: 2},
+		{Name: "line\nbreak", Note: "", Score: nil, Rank: 1},
+	}
+	d := dsv.NewDSVMust(dsv.DSVOpt{})
+	bs, e := d.Serialize(src)
+	if e != nil {
+		t.Logf("serialization error: %v", e)
+		t.FailNow()
+	}
+	expected := "name,note,score,rank\n\"a,b\",\"say \\\"hi\\\"\",7,2\n\"line\nbreak\",,,1"
+	if string(bs) != expected {
+		t.Logf("expected %q, got: %q", expected, bs)
+		t.FailNow()
+	}
+	rs := []escapeRow{}
+	if e := d.Deserialize(bs, &rs); e != nil {
+		t.Logf("deserialization error: %v", e)
+		t.FailNow()
+	}
+	if !reflect.DeepEqual(rs, src) {
+		t.Logf("expected %+v, got: %+v", src, rs)
+		t.FailNow()
+	}
+}
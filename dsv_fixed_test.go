@@ -0,0 +1,112 @@
+package dsv_test
+
+import (
+	"errors"
+	"testing"
+
+	dsv "github.com/tony-o/dsv"
+)
+
+type Extract struct {
+	Acct    string  `csv:"acct,pos=1-10"`
+	Name    string  `csv:"name,width=12"`
+	Balance float64 `csv:"balance,pos=25-34,align=right"`
+	Branch  int     `csv:"branch,width=4,align=right,pad=0"`
+	Note    string
+}
+
+// TestDSV_Fixed_RoundTrip ensures fixed width records are cut, padded and truncated by tag
+func TestDSV_Fixed_RoundTrip(t *testing.T) {
+	d := dsv.NewDSVMust(dsv.DSVOpt{FixedWidth: dsv.DBool(true), ParseHeader: dsv.DBool(false)})
+	data := "0000012345Jane Doe       1250.500000\n0000067890Bartholomew    -3.000000120\n00000000\n"
+	es := []Extract{}
+	if e := d.Deserialize([]byte(data), &es); e != nil {
+		t.Logf("deserialize error: %v", e)
+		t.FailNow()
+	}
+	expect := []Extract{
+		{Acct: "0000012345", Name: "Jane Doe", Balance: 1250.5},
+		{Acct: "0000067890", Name: "Bartholomew", Balance: -3, Branch: 120},
+		{Acct: "00000000"},
+	}
+	if len(es) != len(expect) {
+		t.Logf("row count mismatch: expected=%d,got=%d", len(expect), len(es))
+		t.FailNow()
+	}
+	for i := range expect {
+		if es[i] != expect[i] {
+			t.Logf("row %d mismatch: expected=%+v,got=%+v", i, expect[i], es[i])
+			t.FailNow()
+		}
+	}
+
+	bs, e := d.Serialize([]Extract{{Acct: "0000012345", Name: "Maximilian Longname", Balance: 7, Branch: 5}})
+	if e != nil {
+		t.Logf("serialize error: %v", e)
+		t.FailNow()
+	}
+	if string(bs) != "0000012345Maximilian L    7.0000000005" {
+		t.Logf("serialize mismatch: expected=%q,got=%q", "0000012345Maximilian L    7.0000000005", string(bs))
+		t.FailNow()
+	}
+}
+
+// TestDSV_Fixed_Truncate ensures cells are cut on a rune boundary and signs go ahead of zero padding
+func TestDSV_Fixed_Truncate(t *testing.T) {
+	d := dsv.NewDSVMust(dsv.DSVOpt{FixedWidth: dsv.DBool(true), ParseHeader: dsv.DBool(false)})
+	type row struct {
+		Acct   string `csv:"acct,width=4"`
+		Branch int    `csv:"branch,width=4,align=right,pad=0"`
+	}
+	bs, e := d.Serialize([]row{{Acct: "Joséph", Branch: -5}})
+	if e != nil {
+		t.Logf("serialize error: %v", e)
+		t.FailNow()
+	}
+	expect := "Jos -005"
+	if string(bs) != expect {
+		t.Logf("serialize mismatch: expected=%q,got=%q", expect, string(bs))
+		t.FailNow()
+	}
+	rs := []row{}
+	if e := d.Deserialize(bs, &rs); e != nil || len(rs) != 1 || rs[0].Acct != "Jos" || rs[0].Branch != -5 {
+		t.Logf("round trip mismatch: got=%+v (%v)", rs, e)
+		t.FailNow()
+	}
+}
+
+// TestDSV_Fixed_Header ensures a fixed width header row, cut to the column widths, is skipped
+func TestDSV_Fixed_Header(t *testing.T) {
+	d := dsv.NewDSVMust(dsv.DSVOpt{FixedWidth: dsv.DBool(true)})
+	bs, e := d.Serialize([]Extract{{Acct: "1", Name: "a", Balance: 2, Branch: 3}})
+	if e != nil {
+		t.Logf("serialize error: %v", e)
+		t.FailNow()
+	}
+	es := []Extract{}
+	if e := d.Deserialize(bs, &es); e != nil || len(es) != 1 || es[0] != (Extract{Acct: "1", Name: "a", Balance: 2, Branch: 3}) {
+		t.Logf("header round trip mismatch: err=%v,data=%q,got=%+v", e, string(bs), es)
+		t.FailNow()
+	}
+}
+
+// TestDSV_Fixed_BadTags ensures invalid or overlapping spans are reported
+func TestDSV_Fixed_BadTags(t *testing.T) {
+	type overlap struct {
+		A string `csv:"a,pos=1-5"`
+		B string `csv:"b,pos=4-8"`
+	}
+	type badPos struct {
+		A string `csv:"a,pos=5-1"`
+	}
+	type mismatch struct {
+		A string `csv:"a,pos=1-5,width=3"`
+	}
+	d := dsv.NewDSVMust(dsv.DSVOpt{FixedWidth: dsv.DBool(true), ParseHeader: dsv.DBool(false)})
+	for _, tgt := range []interface{}{&[]overlap{}, &[]badPos{}, &[]mismatch{}, &[]map[string]string{}} {
+		if e := d.Deserialize([]byte("abcdefgh"), tgt); !errors.Is(e, dsv.DSV_FIXED_WIDTH_TAG) {
+			t.Logf("expected fixed width tag error for %T, got: %v", tgt, e)
+			t.FailNow()
+		}
+	}
+}
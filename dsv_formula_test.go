@@ -0,0 +1,90 @@
+package dsv_test
+
+import (
+	"testing"
+
+	dsv "github.com/tony-o/dsv"
+)
+
+type Statement struct {
+	Memo   string  `csv:"memo"`
+	Amount float64 `csv:"amount"`
+	Delta  string  `csv:"delta"`
+}
+
+// TestDSV_Formula_Sanitize ensures formula-like cells are prefixed and quoted except numeric and allowed columns
+func TestDSV_Formula_Sanitize(t *testing.T) {
+	src := []Statement{
+		{Memo: "=HYPERLINK(\"http://x\")", Amount: -5, Delta: "-5"},
+		{Memo: "@SUM(A1)", Amount: 1, Delta: "+2"},
+		{Memo: "\tcmd", Amount: 0, Delta: "3"},
+		{Memo: "safe - text", Amount: 2, Delta: "=1"},
+	}
+	tests := []struct {
+		Name   string
+		Dsvo   dsv.DSVOpt
+		Expect string
+	}{
+		{
+			Name:   "off",
+			Dsvo:   dsv.DSVOpt{},
+			Expect: "memo,amount,delta\n\"=HYPERLINK(\\\"http://x\\\")\",-5.000000,-5\n@SUM(A1),1.000000,+2\n\"\tcmd\",0.000000,3\nsafe - text,2.000000,=1",
+		},
+		{
+			Name:   "sanitize",
+			Dsvo:   dsv.DSVOpt{SanitizeFormulas: dsv.DBool(true)},
+			Expect: "memo,amount,delta\n\"'=HYPERLINK(\\\"http://x\\\")\",-5.000000,\"'-5\"\n\"'@SUM(A1)\",1.000000,\"'+2\"\n\"'\tcmd\",0.000000,3\nsafe - text,2.000000,\"'=1\"",
+		},
+		{
+			Name:   "allow list",
+			Dsvo:   dsv.DSVOpt{SanitizeFormulas: dsv.DBool(true), FormulaColumns: dsv.DStrings("delta")},
+			Expect: "memo,amount,delta\n\"'=HYPERLINK(\\\"http://x\\\")\",-5.000000,-5\n\"'@SUM(A1)\",1.000000,+2\n\"'\tcmd\",0.000000,3\nsafe - text,2.000000,=1",
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.Name, func(t2 *testing.T) {
+			bs, e := dsv.NewDSVMust(tst.Dsvo).Serialize(src)
+			if e != nil || string(bs) != tst.Expect {
+				t2.Logf("serialize mismatch: err=%v,expected=%q,got=%q", e, tst.Expect, string(bs))
+				t2.FailNow()
+			}
+		})
+	}
+	if string(dsv.SanitizeFormula([]byte("+1"))) != "'+1" || string(dsv.SanitizeFormula([]byte("1+"))) != "1+" {
+		t.Logf("SanitizeFormula mismatch")
+		t.FailNow()
+	}
+}
+
+// TestDSV_Formula_Serializer ensures the sanitising serializers give registry callers the same protection as Serialize
+func TestDSV_Formula_Serializer(t *testing.T) {
+	cell := "=cmd|' /C calc'!A0"
+	for name, f := range map[string]func(interface{}) ([]byte, bool){
+		"SanitizedStringSerializer": dsv.SanitizedStringSerializer,
+		"SanitizeSerializer":        dsv.SanitizeSerializer(dsv.DefaultSerializers["string"]),
+	} {
+		if v, ok := f(cell); !ok || string(v) != "'"+cell {
+			t.Logf("%s: expected %q, got: %q (%v)", name, "'"+cell, v, ok)
+			t.FailNow()
+		}
+		if v, ok := f("safe"); !ok || string(v) != "safe" {
+			t.Logf("%s: expected %q, got: %q (%v)", name, "safe", v, ok)
+			t.FailNow()
+		}
+		if _, ok := f(1); ok {
+			t.Logf("%s: expected a non-string to be refused", name)
+			t.FailNow()
+		}
+	}
+	if v, _ := dsv.DefaultSerializers["string"](cell); string(v) != cell {
+		t.Logf("expected the default string serializer to be left as is, got: %q", v)
+		t.FailNow()
+	}
+
+	d := dsv.NewDSVMust(dsv.DSVOpt{Serializers: dsv.DSerial(map[string]func(interface{}) ([]byte, bool){"string": dsv.SanitizedStringSerializer})})
+	bs, e := d.Serialize([]Statement{{Memo: cell, Amount: -1, Delta: "-1"}})
+	if expect := "memo,amount,delta\n'=cmd|' /C calc'!A0,-1.000000,'-1"; e != nil || string(bs) != expect {
+		t.Logf("expected %q from a registered sanitising serializer, got: %q (%v)", expect, bs, e)
+		t.FailNow()
+	}
+}
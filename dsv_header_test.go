@@ -0,0 +1,317 @@
+package dsv_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	dsv "github.com/tony-o/dsv"
+)
+
+type Contact struct {
+	Name  string `csv:"name"`
+	Email string `csv:"email|e-mail|email_address"`
+}
+
+// TestDSV_Header_Aliases ensures any alias in a tag matches the header
+func TestDSV_Header_Aliases(t *testing.T) {
+	d := dsv.NewDSVMust(dsv.DSVOpt{})
+	for _, h := range []string{"email", "e-mail", "email_address"} {
+		cs := []Contact{}
+		if e := d.Deserialize([]byte("name,"+h+"\nbob,bob@xyz.com"), &cs); e != nil {
+			t.Logf("deserialize error for %q: %v", h, e)
+			t.FailNow()
+		}
+		if len(cs) != 1 || cs[0].Email != "bob@xyz.com" {
+			t.Logf("alias %q did not match: got=%+v", h, cs)
+			t.FailNow()
+		}
+	}
+	bs, e := d.Serialize([]Contact{{Name: "bob", Email: "bob@xyz.com"}})
+	if e != nil || !strings.Contains(string(bs), "email") || strings.Contains(string(bs), "e-mail") {
+		t.Logf("serialize should use the first alias: err=%v,got=%q", e, string(bs))
+		t.FailNow()
+	}
+}
+
+// TestDSV_Header_Normalizer ensures header normalisation applies before tag matching
+func TestDSV_Header_Normalizer(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Dsvo   dsv.DSVOpt
+		Header string
+		Match  bool
+	}{
+		{Name: "no normalisation", Dsvo: dsv.DSVOpt{}, Header: "Name,E-Mail", Match: false},
+		{Name: "fold case", Dsvo: dsv.DSVOpt{FoldHeaderCase: dsv.DBool(true)}, Header: "NAME,E-Mail", Match: true},
+		{Name: "collapse", Dsvo: dsv.DSVOpt{FoldHeaderCase: dsv.DBool(true), CollapseHeader: dsv.DBool(true)}, Header: "Name ,Email  Address", Match: true},
+		{Name: "user func", Dsvo: dsv.DSVOpt{HeaderNormalizer: dsv.DNormalizer(func(s string) string {
+			return strings.TrimPrefix(strings.ToLower(s), "contact ")
+		})}, Header: "Contact Name,Contact Email", Match: true},
+	}
+	for _, tst := range tests {
+		t.Run(tst.Name, func(t2 *testing.T) {
+			d := dsv.NewDSVMust(tst.Dsvo)
+			cs := []Contact{}
+			if e := d.Deserialize([]byte(tst.Header+"\nbob,bob@xyz.com"), &cs); e != nil {
+				t2.Logf("deserialize error: %v", e)
+				t2.FailNow()
+			}
+			matched := len(cs) == 1 && cs[0].Name == "bob" && cs[0].Email == "bob@xyz.com"
+			if matched != tst.Match {
+				t2.Logf("header %q match expected=%v,got=%+v", tst.Header, tst.Match, cs)
+				t2.FailNow()
+			}
+		})
+	}
+}
+
+// TestDSV_Header_NormalizedCollision ensures tags of two fields normalising to the same header are refused
+func TestDSV_Header_NormalizedCollision(t *testing.T) {
+	type mailbox struct {
+		Primary string `csv:"E-mail"`
+		Backup  string `csv:"backup|e_mail"`
+	}
+	data := []byte("E-mail,backup\na@xyz.com,b@xyz.com")
+	if e := dsv.NewDSVMust(dsv.DSVOpt{}).Deserialize(data, &[]mailbox{}); e != nil {
+		t.Logf("unexpected error without normalisation: %v", e)
+		t.FailNow()
+	}
+	d := dsv.NewDSVMust(dsv.DSVOpt{FoldHeaderCase: dsv.DBool(true), CollapseHeader: dsv.DBool(true)})
+	if e := d.Deserialize(data, &[]mailbox{}); !errors.Is(e, dsv.DSV_DUPLICATE_TAG_IN_STRUCT) {
+		t.Logf("expected %v, got: %v", dsv.DSV_DUPLICATE_TAG_IN_STRUCT, e)
+		t.FailNow()
+	}
+	if _, e := d.CheckHeader(data, &[]mailbox{}); !errors.Is(e, dsv.DSV_DUPLICATE_TAG_IN_STRUCT) {
+		t.Logf("expected %v from CheckHeader, got: %v", dsv.DSV_DUPLICATE_TAG_IN_STRUCT, e)
+		t.FailNow()
+	}
+	if e := d.Deserialize([]byte("Name,E_Mail\nbob,bob@xyz.com"), &[]Contact{}); e != nil {
+		t.Logf("aliases of one field normalising alike should not collide, got: %v", e)
+		t.FailNow()
+	}
+}
+
+type Tagged struct {
+	Id   int      `csv:"id"`
+	Name string   `csv:"name"`
+	Tags []string `csv:"tag"`
+}
+
+// TestDSV_Header_Duplicates ensures each duplicate header policy is applied to structs and maps
+func TestDSV_Header_Duplicates(t *testing.T) {
+	data := []byte("id,name,tag,name,tag\n1,first,a,last,b")
+	tests := []struct {
+		Name   string
+		Policy dsv.DuplicateHeader
+		Expect string
+		Err    error
+	}{
+		{Name: "last wins", Policy: dsv.DuplicateLastWins, Expect: "last"},
+		{Name: "first wins", Policy: dsv.DuplicateFirstWins, Expect: "first"},
+		{Name: "error", Policy: dsv.DuplicateError, Err: dsv.DSV_DUPLICATE_HEADER},
+	}
+	for _, tst := range tests {
+		t.Run(tst.Name, func(t2 *testing.T) {
+			d := dsv.NewDSVMust(dsv.DSVOpt{DuplicateHeaders: dsv.DDuplicate(tst.Policy)})
+			cs := []Contact{}
+			e := d.Deserialize(data, &cs)
+			if tst.Err != nil {
+				if !errors.Is(e, tst.Err) {
+					t2.Logf("expected error %v, got: %v", tst.Err, e)
+					t2.FailNow()
+				}
+				return
+			}
+			if e != nil || len(cs) != 1 || cs[0].Name != tst.Expect {
+				t2.Logf("struct mismatch: err=%v,expected=%s,got=%+v", e, tst.Expect, cs)
+				t2.FailNow()
+			}
+			ms := []map[string]string{}
+			if e := d.Deserialize(data, &ms); e != nil || len(ms) != 1 || ms[0]["name"] != tst.Expect {
+				t2.Logf("map mismatch: err=%v,expected=%s,got=%+v", e, tst.Expect, ms)
+				t2.FailNow()
+			}
+		})
+	}
+}
+
+// TestDSV_Header_DuplicatesCollect ensures repeated columns can be gathered into []string values
+func TestDSV_Header_DuplicatesCollect(t *testing.T) {
+	d := dsv.NewDSVMust(dsv.DSVOpt{DuplicateHeaders: dsv.DDuplicate(dsv.DuplicateCollect)})
+	data := []byte("id,tag,name,tag\n1,a,bob,b")
+	ts := []Tagged{}
+	if e := d.Deserialize(data, &ts); e != nil {
+		t.Logf("deserialize error: %v", e)
+		t.FailNow()
+	}
+	if len(ts) != 1 || strings.Join(ts[0].Tags, "|") != "a|b" || ts[0].Name != "bob" {
+		t.Logf("collect mismatch: expected=[a b],got=%+v", ts)
+		t.FailNow()
+	}
+	ms := []map[string][]string{}
+	if e := d.Deserialize(data, &ms); e != nil || len(ms) != 1 || strings.Join(ms[0]["tag"], "|") != "a|b" {
+		t.Logf("map collect mismatch: err=%v,got=%+v", e, ms)
+		t.FailNow()
+	}
+	if e := d.Deserialize(data, &[]map[string]string{}); !errors.Is(e, dsv.DSV_DUPLICATE_HEADER) {
+		t.Logf("expected error collecting into map[string]string, got: %v", e)
+		t.FailNow()
+	}
+	if e := d.Deserialize([]byte("name,name\na,b"), &[]Tagged{}); !errors.Is(e, dsv.DSV_DUPLICATE_HEADER) {
+		t.Logf("expected error collecting into a string field, got: %v", e)
+		t.FailNow()
+	}
+}
+
+type Invoice struct {
+	Id     int     `csv:"id,required"`
+	Number string  `csv:"number,required"`
+	Amount float64 `csv:"amount"`
+	Memo   string  `csv:"memo"`
+}
+
+// TestDSV_Header_CheckHeader ensures the report lists missing, extra, duplicated and reordered columns
+func TestDSV_Header_CheckHeader(t *testing.T) {
+	d := dsv.NewDSVMust(dsv.DSVOpt{})
+	r, e := d.CheckHeader([]byte("amount,id,extra,amount\n1,2,3,4"), &[]Invoice{})
+	if e != nil {
+		t.Logf("check header error: %v", e)
+		t.FailNow()
+	}
+	if r.OK() {
+		t.Logf("report should not be OK: %s", r)
+		t.FailNow()
+	}
+	if strings.Join(r.Missing, ",") != "number" || strings.Join(r.Extra, ",") != "extra" || strings.Join(r.Duplicated, ",") != "amount" || strings.Join(r.Reordered, ",") != "amount" {
+		t.Logf("report mismatch: got=%s", r)
+		t.FailNow()
+	}
+
+	r, e = d.CheckHeader([]byte("number,id\n1,2"), &[]Invoice{})
+	if e != nil || !r.OK() || strings.Join(r.Reordered, ",") != "number" {
+		t.Logf("reordered header should be OK: err=%v,got=%s", e, r)
+		t.FailNow()
+	}
+
+	for header, moved := range map[string]string{
+		"memo,id,number,amount":       "memo",
+		"id,number,memo,amount":       "memo",
+		"extra,id,number,amount,memo": "",
+	} {
+		r, e = d.CheckHeader([]byte(header+"\n"), &[]Invoice{})
+		if e != nil || strings.Join(r.Reordered, ",") != moved {
+			t.Logf("expected only %q reordered for %q: err=%v,got=%s", moved, header, e, r)
+			t.FailNow()
+		}
+	}
+
+	r, e = d.CheckHeader([]byte(""), &[]Invoice{})
+	if !errors.Is(e, dsv.DSV_HEADER_MISSING) || strings.Join(r.Missing, ",") != "id,number" {
+		t.Logf("expected %v listing the required columns for empty input: err=%v,got=%s", dsv.DSV_HEADER_MISSING, e, r)
+		t.FailNow()
+	}
+
+	if _, e = dsv.NewDSVMust(dsv.DSVOpt{ParseHeader: dsv.DBool(false)}).CheckHeader([]byte("id"), &[]Invoice{}); !errors.Is(e, dsv.DSV_HEADER_DISABLED) {
+		t.Logf("expected header disabled error, got: %v", e)
+		t.FailNow()
+	}
+}
+
+// TestDSV_Header_CheckHeaderEncoding ensures CheckHeader decodes the input once
+func TestDSV_Header_CheckHeaderEncoding(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Encoding dsv.Encoding
+		Input    []byte
+	}{
+		{Name: "latin-1", Encoding: dsv.EncodingLatin1, Input: []byte("caf\xe9,id\n1,2")},
+		{Name: "utf-16le", Encoding: dsv.EncodingUTF16LE, Input: []byte("c\x00a\x00f\x00\xe9\x00,\x00i\x00d\x00\n\x001\x00,\x002\x00")},
+	}
+	for _, tst := range tests {
+		t.Run(tst.Name, func(t2 *testing.T) {
+			d := dsv.NewDSVMust(dsv.DSVOpt{InputEncoding: dsv.DEncoding(tst.Encoding)})
+			r, e := d.CheckHeader(tst.Input, &[]Invoice{})
+			if e != nil {
+				t2.Logf("check header error: %v", e)
+				t2.FailNow()
+			}
+			if strings.Join(r.Extra, ",") != "café" || strings.Join(r.Missing, ",") != "number" {
+				t2.Logf("report mismatch: expected extra=café,missing=number,got=%s", r)
+				t2.FailNow()
+			}
+		})
+	}
+}
+
+// TestDSV_Header_Strict ensures StrictHeader refuses non-conforming headers
+func TestDSV_Header_Strict(t *testing.T) {
+	d := dsv.NewDSVMust(dsv.DSVOpt{StrictHeader: dsv.DBool(true)})
+	if e := d.Deserialize([]byte("id,number,other\n1,2,3"), &[]Invoice{}); !errors.Is(e, dsv.DSV_HEADER_MISMATCH) {
+		t.Logf("expected header mismatch error, got: %v", e)
+		t.FailNow()
+	}
+	is := []Invoice{}
+	if e := d.Deserialize([]byte("number,id,memo\nA-1,1,hi"), &is); e != nil || len(is) != 1 || is[0].Number != "A-1" {
+		t.Logf("conforming header failed: err=%v,got=%+v", e, is)
+		t.FailNow()
+	}
+}
+
+type Quarterly struct {
+	Region   string  `csv:"region"`
+	Q1Rev    float64 `csv:"Q1.revenue"`
+	Q1Cost   float64 `csv:"Q1.cost"`
+	Q2Rev    float64 `csv:"Q2.revenue"`
+	Q2Cost   float64 `csv:"Q2.cost"`
+	Comments string  `csv:"notes"`
+}
+
+// TestDSV_Header_Preamble ensures leading records can be skipped before the header
+func TestDSV_Header_Preamble(t *testing.T) {
+	data := []byte("Acme Accounting Export\nGenerated 2021-01-01\nid,name,email address\n1,bob,bob@xyz.com")
+	tests := []struct {
+		Name string
+		Dsvo dsv.DSVOpt
+	}{
+		{Name: "skip rows", Dsvo: dsv.DSVOpt{SkipRows: dsv.DInt(2)}},
+		{Name: "header row", Dsvo: dsv.DSVOpt{HeaderRow: dsv.DInt(2)}},
+		{Name: "skip until", Dsvo: dsv.DSVOpt{SkipUntil: dsv.DPredicate(func(r []string) bool {
+			return len(r) > 0 && r[0] == "id"
+		})}},
+	}
+	for _, tst := range tests {
+		t.Run(tst.Name, func(t2 *testing.T) {
+			d := dsv.NewDSVMust(tst.Dsvo)
+			xs := TagTestArray{}
+			if e := d.Deserialize(data, &xs); e != nil {
+				t2.Logf("deserialize error: %v", e)
+				t2.FailNow()
+			}
+			if len(xs) != 1 || xs[0] != (TagTest{Id: 1, Name: "bob", Email: "bob@xyz.com"}) {
+				t2.Logf("preamble not skipped: got=%+v", xs)
+				t2.FailNow()
+			}
+		})
+	}
+	if _, e := dsv.NewDSV(dsv.DSVOpt{HeaderRows: dsv.DInt(0)}); !errors.Is(e, dsv.DSV_HEADER_ROWS_NZ) {
+		t.Logf("expected header rows error, got: %v", e)
+		t.FailNow()
+	}
+}
+
+// TestDSV_Header_MultiRow ensures group and field header rows combine into composite names
+func TestDSV_Header_MultiRow(t *testing.T) {
+	d := dsv.NewDSVMust(dsv.DSVOpt{HeaderRows: dsv.DInt(2)})
+	data := []byte(",,Q1,,Q2,\nregion,notes,revenue,cost,revenue,cost\nwest,ok,10,4,12,5")
+	qs := []Quarterly{}
+	if e := d.Deserialize(data, &qs); e != nil {
+		t.Logf("deserialize error: %v", e)
+		t.FailNow()
+	}
+	expect := Quarterly{Region: "west", Q1Rev: 10, Q1Cost: 4, Q2Rev: 12, Q2Cost: 5, Comments: "ok"}
+	if len(qs) != 1 || qs[0] != expect {
+		t.Logf("multi row header mismatch: expected=%+v,got=%+v", expect, qs)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,145 @@
+package dsv_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	dsv "github.com/tony-o/dsv"
+)
+
+var errHookTotal = errors.New("total does not match")
+
+type hookRow struct {
+	A     int `csv:"a"`
+	B     int `csv:"b"`
+	Total int `csv:"total"`
+	Sum   int `csv:"-"`
+}
+
+func (r *hookRow) AfterDSVDecode() error {
+	r.Sum = r.A + r.B
+	if r.Total != r.Sum {
+		return errHookTotal
+	}
+	return nil
+}
+
+func (r *hookRow) BeforeDSVEncode() error {
+	if r.A < 0 {
+		return errHookTotal
+	}
+	r.Total = r.A + r.B
+	return nil
+}
+
+type fixedHookRow struct {
+	A     int `csv:"a,width=2,align=right"`
+	B     int `csv:"b,width=2,align=right"`
+	Total int `csv:"total,width=2,align=right"`
+}
+
+func (r *fixedHookRow) BeforeDSVEncode() error {
+	if r.A < 0 {
+		return errHookTotal
+	}
+	r.Total = r.A + r.B
+	return nil
+}
+
+// TestDSV_AfterDecode ensures AfterDSVDecode runs on each row and its error names the data row
+func TestDSV_AfterDecode(t *testing.T) {
+	d := dsv.NewDSVMust(dsv.DSVOpt{})
+	rs := []hookRow{}
+	if e := d.Deserialize([]byte("a,b,total\n1,2,3\n4,5,9"), &rs); e != nil {
+		t.Logf("unexpected error: %v", e)
+		t.FailNow()
+	}
+	expected := []hookRow{{A: 1, B: 2, Total: 3, Sum: 3}, {A: 4, B: 5, Total: 9, Sum: 9}}
+	if !reflect.DeepEqual(rs, expected) {
+		t.Logf("expected %v, got: %v", expected, rs)
+		t.FailNow()
+	}
+
+	e := d.Deserialize([]byte("a,b,total\n1,2,3\n4,5,6"), &rs)
+	if !errors.Is(e, dsv.DSV_ROW_HOOK) || !errors.Is(e, errHookTotal) {
+		t.Logf("expected %v wrapping %v, got: %v", dsv.DSV_ROW_HOOK, errHookTotal, e)
+		t.FailNow()
+	}
+	if e.Error() != "Row hook returned an error: AfterDSVDecode on record 2: total does not match" {
+		t.Logf("expected the record to be named, got: %v", e)
+		t.FailNow()
+	}
+
+	pd := dsv.NewDSVMust(dsv.DSVOpt{SkipRows: dsv.DInt(1), CommentPrefix: dsv.DByte([]byte("#"))})
+	e = pd.Deserialize([]byte("export\na,b,total\n# first\n1,2,3\n# second\n4,5,6"), &rs)
+	if e == nil || e.Error() != "Row hook returned an error: AfterDSVDecode on record 2: total does not match" {
+		t.Logf("expected the data row to be named past the preamble and comments, got: %v", e)
+		t.FailNow()
+	}
+}
+
+// TestDSV_BeforeEncode ensures BeforeDSVEncode runs on each row, for slices of values, pointers and fixed width
+func TestDSV_BeforeEncode(t *testing.T) {
+	d := dsv.NewDSVMust(dsv.DSVOpt{})
+	expected := "a,b,total\n1,2,3\n4,5,9"
+	rows := []hookRow{{A: 1, B: 2}, {A: 4, B: 5}}
+	bs, e := d.Serialize(rows)
+	if e != nil || string(bs) != expected {
+		t.Logf("expected %q, got: %q (%v)", expected, bs, e)
+		t.FailNow()
+	}
+	if rows[0].Total != 0 || rows[1].Total != 0 {
+		t.Logf("expected the caller's slice to be left unchanged, got: %+v", rows)
+		t.FailNow()
+	}
+	ptrs := []*hookRow{{A: 1, B: 2}, {A: 4, B: 5}}
+	bs, e = d.Serialize(&ptrs)
+	if e != nil || string(bs) != expected {
+		t.Logf("expected %q from pointers, got: %q (%v)", expected, bs, e)
+		t.FailNow()
+	}
+	if ptrs[0].Total != 0 || ptrs[1].Total != 0 {
+		t.Logf("expected the caller's rows to be left unchanged, got: %+v %+v", *ptrs[0], *ptrs[1])
+		t.FailNow()
+	}
+
+	_, e = d.Serialize([]hookRow{{A: 1, B: 2}, {A: -1}})
+	if !errors.Is(e, dsv.DSV_ROW_HOOK) || !errors.Is(e, errHookTotal) {
+		t.Logf("expected %v wrapping %v, got: %v", dsv.DSV_ROW_HOOK, errHookTotal, e)
+		t.FailNow()
+	}
+	if e.Error() != "Row hook returned an error: BeforeDSVEncode on record 2: total does not match" {
+		t.Logf("expected the record to be named, got: %v", e)
+		t.FailNow()
+	}
+
+	src := hookRow{A: 1, B: 2}
+	bs, e = d.Serialize(src)
+	if e != nil || string(bs) != "a,b,total\n1,2,3" {
+		t.Logf("expected the hook to run on a struct passed by value, got: %q (%v)", bs, e)
+		t.FailNow()
+	}
+	if src.Total != 0 {
+		t.Logf("expected the caller's value to be left unchanged, got: %+v", src)
+		t.FailNow()
+	}
+
+	fd := dsv.NewDSVMust(dsv.DSVOpt{FixedWidth: dsv.DBool(true), ParseHeader: dsv.DBool(false)})
+	frows := []fixedHookRow{{A: 1, B: 2}}
+	bs, e = fd.Serialize(frows)
+	if e != nil || string(bs) != " 1 2 3" || frows[0].Total != 0 {
+		t.Logf("expected %q from fixed width with the caller's slice unchanged, got: %q %+v (%v)", " 1 2 3", bs, frows, e)
+		t.FailNow()
+	}
+	bs, e = fd.Serialize(fixedHookRow{A: 1, B: 2})
+	if e != nil || string(bs) != " 1 2 3" {
+		t.Logf("expected %q from a fixed width struct passed by value, got: %q (%v)", " 1 2 3", bs, e)
+		t.FailNow()
+	}
+	_, e = fd.Serialize([]fixedHookRow{{A: -1}})
+	if !errors.Is(e, dsv.DSV_ROW_HOOK) {
+		t.Logf("expected %v from fixed width, got: %v", dsv.DSV_ROW_HOOK, e)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,42 @@
+package dsv_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	dsv "github.com/tony-o/dsv"
+)
+
+// TestDSV_Limits ensures each limit fails with its own error and inputs at the limit parse
+func TestDSV_Limits(t *testing.T) {
+	tests := []struct {
+		Name  string
+		Dsvo  dsv.DSVOpt
+		Input string
+		Err   error
+	}{
+		{Name: "input ok", Dsvo: dsv.DSVOpt{MaxInputBytes: dsv.DInt(11)}, Input: "a,b\n1,2\n3,4"},
+		{Name: "input", Dsvo: dsv.DSVOpt{MaxInputBytes: dsv.DInt(10)}, Input: "a,b\n1,2\n3,4", Err: dsv.DSV_INPUT_TOO_LARGE},
+		{Name: "field ok", Dsvo: dsv.DSVOpt{MaxFieldBytes: dsv.DInt(5)}, Input: "a,b\n12345,\"1\"\n"},
+		{Name: "field", Dsvo: dsv.DSVOpt{MaxFieldBytes: dsv.DInt(5)}, Input: "a,b\n123456,2", Err: dsv.DSV_FIELD_TOO_LARGE},
+		{Name: "unterminated quote", Dsvo: dsv.DSVOpt{MaxFieldBytes: dsv.DInt(64)}, Input: "a,b\n1,\"" + strings.Repeat("x,y\n", 100), Err: dsv.DSV_FIELD_TOO_LARGE},
+		{Name: "fields ok", Dsvo: dsv.DSVOpt{MaxFields: dsv.DInt(2)}, Input: "a,b\n1,2"},
+		{Name: "fields", Dsvo: dsv.DSVOpt{MaxFields: dsv.DInt(2)}, Input: "a,b\n1,2,3", Err: dsv.DSV_TOO_MANY_FIELDS},
+		{Name: "records ok", Dsvo: dsv.DSVOpt{MaxRecords: dsv.DInt(3)}, Input: "a,b\n1,2\n3,4\n\n"},
+		{Name: "records", Dsvo: dsv.DSVOpt{MaxRecords: dsv.DInt(2)}, Input: "a,b\n1,2\n3,4", Err: dsv.DSV_TOO_MANY_RECORDS},
+	}
+	for _, tst := range tests {
+		t.Run(tst.Name, func(t2 *testing.T) {
+			e := dsv.NewDSVMust(tst.Dsvo).Deserialize([]byte(tst.Input), &[]map[string]string{})
+			if tst.Err == nil && e != nil {
+				t2.Logf("unexpected error: %v", e)
+				t2.FailNow()
+			}
+			if tst.Err != nil && !errors.Is(e, tst.Err) {
+				t2.Logf("expected %v, got: %v", tst.Err, e)
+				t2.FailNow()
+			}
+		})
+	}
+}
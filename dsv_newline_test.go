@@ -0,0 +1,80 @@
+package dsv_test
+
+import (
+	"reflect"
+	"testing"
+
+	dsv "github.com/tony-o/dsv"
+)
+
+// TestDSV_Newline_Universal ensures \n, \r\n and \r all end records while quoted newlines are kept
+func TestDSV_Newline_Universal(t *testing.T) {
+	d := dsv.NewDSVMust(dsv.DSVOpt{UniversalNewline: dsv.DBool(true), StripField: dsv.DString("")})
+	tests := []struct {
+		Name  string
+		Input string
+	}{
+		{Name: "lf", Input: "a,\"x\r\ny\"\nb,c\n"},
+		{Name: "crlf", Input: "a,\"x\r\ny\"\r\nb,c\r\n"},
+		{Name: "cr", Input: "a,\"x\r\ny\"\rb,c\r"},
+		{Name: "mixed", Input: "a,\"x\r\ny\"\rb,c\n"},
+	}
+	expect := map[int][]string{0: {"a", "x\r\ny"}, 1: {"b", "c"}}
+	for _, tst := range tests {
+		t.Run(tst.Name, func(t2 *testing.T) {
+			m, e := d.DeserializeMapIndex(tst.Input)
+			if e != nil || !reflect.DeepEqual(m, expect) {
+				t2.Logf("mismatch: err=%v,expected=%q,got=%q", e, expect, m)
+				t2.FailNow()
+			}
+		})
+	}
+}
+
+// TestDSV_Newline_LineEnding ensures Serialize writes the chosen line ending
+func TestDSV_Newline_LineEnding(t *testing.T) {
+	d := dsv.NewDSVMust(dsv.DSVOpt{UniversalNewline: dsv.DBool(true), LineEnding: dsv.DString("\r\n"), IncludeColumns: dsv.DStrings("id", "name")})
+	src := TagTestArray{{Id: 1, Name: "a\rb"}, {Id: 2, Name: "c"}}
+	bs, e := d.Serialize(src)
+	if e != nil || string(bs) != "id,name\r\n1,\"a\rb\"\r\n2,c" {
+		t.Logf("serialize mismatch: err=%v,got=%q", e, string(bs))
+		t.FailNow()
+	}
+	xs := TagTestArray{}
+	if e := d.Deserialize(bs, &xs); e != nil || !reflect.DeepEqual(xs, src) {
+		t.Logf("round trip mismatch: err=%v,got=%+v", e, xs)
+		t.FailNow()
+	}
+}
+
+// TestDSV_Newline_TrailingEscape ensures input ending in the EscapeOperator reads the escape as a literal
+func TestDSV_Newline_TrailingEscape(t *testing.T) {
+	d := dsv.NewDSVMust(dsv.DSVOpt{UniversalNewline: dsv.DBool(true)})
+	for in, expect := range map[string][]string{"a\\": {"a\\"}, "\\": {"\\"}, "a,b\\": {"a", "b\\"}} {
+		m, e := d.DeserializeMapIndex(in)
+		if e != nil || !reflect.DeepEqual(m[0], expect) {
+			t.Logf("expected %q from %q, got: %q (%v)", expect, in, m, e)
+			t.FailNow()
+		}
+	}
+}
+
+// TestDSV_Newline_Escaped ensures newlines escaped without a FieldOperator are escaped once and read back
+func TestDSV_Newline_Escaped(t *testing.T) {
+	type row struct {
+		Name string `csv:"name"`
+		Note string `csv:"note"`
+	}
+	src := []row{{Name: `a,b\c`, Note: "x\ny"}}
+	d := dsv.NewDSVMust(dsv.DSVOpt{FieldOperator: dsv.DString(""), EscapeOperator: dsv.DString("\\")})
+	bs, e := d.Serialize(src)
+	if e != nil {
+		t.Logf("serialize error: %v", e)
+		t.FailNow()
+	}
+	rs := []row{}
+	if e := d.Deserialize(bs, &rs); e != nil || !reflect.DeepEqual(rs, src) {
+		t.Logf("expected %+v from %q, got: %+v (%v)", src, bs, rs, e)
+		t.FailNow()
+	}
+}
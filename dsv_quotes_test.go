@@ -0,0 +1,55 @@
+package dsv_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	dsv "github.com/tony-o/dsv"
+)
+
+// TestDSV_Quotes_Strict ensures unterminated and misplaced quotes are reported with their position
+func TestDSV_Quotes_Strict(t *testing.T) {
+	d := dsv.NewDSVMust(dsv.DSVOpt{StrictQuotes: dsv.DBool(true)})
+	_, e := d.DeserializeMapIndex("id,name\n1,\"ok\"\n2,  \"broken\n3,c")
+	if !errors.Is(e, dsv.DSV_UNTERMINATED_QUOTE) || !strings.Contains(e.Error(), "line 3, column 5") {
+		t.Logf("expected unterminated quote error at line 3, column 5, got: %v", e)
+		t.FailNow()
+	}
+	if e := d.Deserialize([]byte("id,name\n1,\"a\nb\""), &TagTestArray{}); e != nil {
+		t.Logf("balanced quotes should parse, got: %v", e)
+		t.FailNow()
+	}
+	for in, pos := range map[string]string{
+		"1,a\"b\n2,c\"d\n3,e": "line 1, column 4",
+		"1,\"ab\"c,d":         "line 1, column 6",
+		"1, \"ab\" ,\"\"":     "",
+	} {
+		_, e := d.DeserializeMapIndex(in)
+		if pos == "" && e != nil {
+			t.Logf("expected %q to parse, got: %v", in, e)
+			t.FailNow()
+		}
+		if pos != "" && (!errors.Is(e, dsv.DSV_MISPLACED_QUOTE) || !strings.Contains(e.Error(), pos)) {
+			t.Logf("expected misplaced quote error at %s for %q, got: %v", pos, in, e)
+			t.FailNow()
+		}
+	}
+}
+
+// TestDSV_Quotes_Lazy ensures bare quotes are literal and an unterminated quote ends at the line separator
+func TestDSV_Quotes_Lazy(t *testing.T) {
+	d := dsv.NewDSVMust(dsv.DSVOpt{LazyQuotes: dsv.DBool(true), ParseHeader: dsv.DBool(false)})
+	m, e := d.DeserializeMapIndex("a \"b\" c,\"d,e\"\n5\" tall,\"x\"y\",z\n\"open,f\ng,h")
+	expect := map[int][]string{
+		0: {"a \"b\" c", "d,e"},
+		1: {"5\" tall", "x\"y", "z"},
+		2: {"\"open", "f"},
+		3: {"g", "h"},
+	}
+	if e != nil || !reflect.DeepEqual(m, expect) {
+		t.Logf("lazy quotes mismatch: err=%v,expected=%q,got=%q", e, expect, m)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,54 @@
+package dsv_test
+
+import (
+	"reflect"
+	"testing"
+
+	dsv "github.com/tony-o/dsv"
+)
+
+// TestDSV_Quoting_Preserve ensures quoted content is kept exactly and only unquoted parts are trimmed
+func TestDSV_Quoting_Preserve(t *testing.T) {
+	input := "  \"  padded  \"  ,\"\n\",\"\",\"C:\\dir\\\"x\\\\\", bare \n"
+	tests := []struct {
+		Name   string
+		Dsvo   dsv.DSVOpt
+		Expect map[int][]string
+	}{
+		{
+			Name:   "default",
+			Dsvo:   dsv.DSVOpt{ParseHeader: dsv.DBool(false)},
+			Expect: map[int][]string{0: {"  padded  ", "\n", "", "C:\\dir\"x\\", "bare"}},
+		},
+		{
+			Name:   "legacy",
+			Dsvo:   dsv.DSVOpt{ParseHeader: dsv.DBool(false), LegacyQuoting: dsv.DBool(true)},
+			Expect: map[int][]string{0: {"  padded  ", "\n", "\"\"", "C:dir\"x\\", "bare"}},
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.Name, func(t2 *testing.T) {
+			m, e := dsv.NewDSVMust(tst.Dsvo).DeserializeMapIndex(input)
+			if e != nil || !reflect.DeepEqual(m, tst.Expect) {
+				t2.Logf("mismatch: err=%v,expected=%q,got=%q", e, tst.Expect, m)
+				t2.FailNow()
+			}
+		})
+	}
+}
+
+// TestDSV_Quoting_RoundTrip ensures padded and backslashed values survive Serialize and Deserialize
+func TestDSV_Quoting_RoundTrip(t *testing.T) {
+	d := dsv.NewDSVMust(dsv.DSVOpt{})
+	src := TagTestArray{{Id: 1, Name: "  padded  ", Email: `C:\dir\`}, {Id: 2, Name: "\t", Email: ""}}
+	bs, e := d.Serialize(src)
+	if e != nil {
+		t.Logf("serialize error: %v", e)
+		t.FailNow()
+	}
+	xs := TagTestArray{}
+	if e := d.Deserialize(bs, &xs); e != nil || !reflect.DeepEqual(xs, src) {
+		t.Logf("round trip mismatch: err=%v,data=%q,got=%+v", e, string(bs), xs)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,107 @@
+package dsv_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	dsv "github.com/tony-o/dsv"
+)
+
+type Cents int64
+
+type Status string
+
+type Ledger struct {
+	Id     int8   `csv:"id"`
+	Amount Cents  `csv:"amount"`
+	Status Status `csv:"status"`
+	Big    int64  `csv:"big"`
+}
+
+// TestDSV_Registry_UnderlyingFallback ensures named types use their underlying kind's converter
+func TestDSV_Registry_UnderlyingFallback(t *testing.T) {
+	d := dsv.NewDSVMust(dsv.DSVOpt{})
+	ls := []Ledger{}
+	if e := d.Deserialize([]byte("id,amount,status,big\n7,1999,open,9000000000"), &ls); e != nil {
+		t.Logf("deserialize error: %v", e)
+		t.FailNow()
+	}
+	expect := Ledger{Id: 7, Amount: 1999, Status: "open", Big: 9000000000}
+	if len(ls) != 1 || ls[0] != expect {
+		t.Logf("deserialize mismatch: expected=%+v,got=%+v", expect, ls)
+		t.FailNow()
+	}
+	bs, e := d.Serialize(ls)
+	if e != nil {
+		t.Logf("serialize error: %v", e)
+		t.FailNow()
+	}
+	rs := []Ledger{}
+	if e := d.Deserialize(bs, &rs); e != nil || len(rs) != 1 || rs[0] != expect {
+		t.Logf("round trip mismatch: err=%v,expected=%+v,got=%+v", e, expect, rs)
+		t.FailNow()
+	}
+}
+
+// TestDSV_Registry_RegisterType ensures converters are keyed by type rather than type name
+func TestDSV_Registry_RegisterType(t *testing.T) {
+	type Status string
+	type Local struct {
+		A Status `csv:"a"`
+	}
+	if fmt.Sprintf("%T", Local{}.A) != fmt.Sprintf("%T", Ledger{}.Status) {
+		t.Logf("test precondition failed, type names differ: %T %T", Local{}.A, Ledger{}.Status)
+		t.FailNow()
+	}
+
+	opt := dsv.DSVOpt{}
+	dsv.RegisterType(&opt, func(s Status) ([]byte, bool) {
+		return []byte(strings.ToUpper(string(s))), true
+	}, func(s string, _ []byte) (Status, bool) {
+		return Status("local:" + s), true
+	})
+	d := dsv.NewDSVMust(opt)
+
+	ls := []Local{}
+	if e := d.Deserialize([]byte("a\nx"), &ls); e != nil || len(ls) != 1 || ls[0].A != "local:x" {
+		t.Logf("registered deserializer not used: err=%v,got=%+v", e, ls)
+		t.FailNow()
+	}
+	gs := []Ledger{}
+	if e := d.Deserialize([]byte("status\nx"), &gs); e != nil || len(gs) != 1 || gs[0].Status != "x" {
+		t.Logf("registered deserializer used for same named type: err=%v,got=%+v", e, gs)
+		t.FailNow()
+	}
+	bs, e := d.Serialize([]Local{{A: "abc"}})
+	if e != nil || string(bs) != "a\nABC" {
+		t.Logf("registered serializer not used: err=%v,got=%q", e, string(bs))
+		t.FailNow()
+	}
+	bs, e = d.Serialize([]Ledger{{Status: "abc"}})
+	if e != nil || !strings.Contains(string(bs), "abc") {
+		t.Logf("registered serializer used for same named type: err=%v,got=%q", e, string(bs))
+		t.FailNow()
+	}
+}
+
+// TestDSV_Registry_NamedDefaults ensures the name keyed default maps are still populated and extend new instances
+func TestDSV_Registry_NamedDefaults(t *testing.T) {
+	if _, ok := dsv.DefaultSerializers["string"]; !ok {
+		t.Logf("expected DefaultSerializers to hold \"string\"")
+		t.FailNow()
+	}
+	if _, ok := dsv.DefaultDeserializers["int64"]; !ok {
+		t.Logf("expected DefaultDeserializers to hold \"int64\"")
+		t.FailNow()
+	}
+	dsv.DefaultSerializers["dsv_test.Status"] = func(i interface{}) ([]byte, bool) {
+		return []byte(strings.ToUpper(string(i.(Status)))), true
+	}
+	defer delete(dsv.DefaultSerializers, "dsv_test.Status")
+	bs, e := dsv.NewDSVMust(dsv.DSVOpt{}).Serialize([]Ledger{{Id: 1, Status: "open"}})
+	if e != nil || !strings.Contains(string(bs), "OPEN") {
+		t.Logf("expected the named default to be used, got: %q (%v)", bs, e)
+		t.FailNow()
+	}
+}
@@ -71,7 +71,7 @@ func (a LottoFields) Cmp(b LottoFields) (bool, string) {
 }
 
 func TestDSV_Serialize_EnsureOrdering(t *testing.T) {
-	return
+	t.Skip("floats are written with six decimals and compared to two, values near a rounding boundary compare unequal")
 	testCase := []LottoFields{}
 	for i := 0; i < 2000; i++ {
 		var b bool
@@ -170,7 +170,7 @@ func TestDSV_Serialize_Basic(t *testing.T) {
 	}
 	var ls TagTestArray = TagTestArray{*(*ts)[0], *(*ts)[1]}
 
-	if ok, _ := TagTestCmp(&xs, &ls); ok {
+	if ok, _ := TagTestCmp(&xs, &ls); !ok {
 		t.Logf("results failure: expected:\"id,name,email address\\n42,nAME,eMAIL\\n64,NaMe,EmAiL\", got:%q", string(bs))
 		t.FailNow()
 	}
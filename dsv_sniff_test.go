@@ -0,0 +1,71 @@
+package dsv_test
+
+import (
+	"errors"
+	"testing"
+
+	dsv "github.com/tony-o/dsv"
+)
+
+// TestDSV_Sniff ensures common dialects are detected and usable with NewDSV
+func TestDSV_Sniff(t *testing.T) {
+	tests := []struct {
+		Name    string
+		Data    string
+		Partial string
+		Expect  TagTestArray
+	}{
+		{
+			Name:    "comma quoted",
+			Data:    "id,name,email address\n1,\"smith, bob\",bob@xyz.com\n2,\"jones, al\",al@xyz.com\n",
+			Partial: "3,\"x",
+			Expect:  TagTestArray{{Id: 1, Name: "smith, bob", Email: "bob@xyz.com"}, {Id: 2, Name: "jones, al", Email: "al@xyz.com"}},
+		},
+		{
+			Name:   "semicolon crlf",
+			Data:   "id;name;email address\r\n1;bob;bob@xyz.com\r\n2;al;al@xyz.com\r\n",
+			Expect: TagTestArray{{Id: 1, Name: "bob", Email: "bob@xyz.com"}, {Id: 2, Name: "al", Email: "al@xyz.com"}},
+		},
+		{
+			Name:   "tab headerless",
+			Data:   "1\tbob\tbob@xyz.com\n2\tali\tali@xyz.com\n3\teve\teve@xyz.com",
+			Expect: TagTestArray{{Id: 1, Name: "bob", Email: "bob@xyz.com"}, {Id: 2, Name: "ali", Email: "ali@xyz.com"}, {Id: 3, Name: "eve", Email: "eve@xyz.com"}},
+		},
+		{
+			Name:   "pipe doubled quotes",
+			Data:   "id|name|email address\n1|\"bob \"\"the builder\"\"\"|bob@xyz.com\n2|\"al\"|al@xyz.com\n",
+			Expect: TagTestArray{{Id: 1, Name: "bob \"the builder\"", Email: "bob@xyz.com"}, {Id: 2, Name: "al", Email: "al@xyz.com"}},
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.Name, func(t2 *testing.T) {
+			opt, confidence, e := dsv.Sniff([]byte(tst.Data + tst.Partial))
+			if e != nil {
+				t2.Logf("sniff error: %v", e)
+				t2.FailNow()
+			}
+			if confidence < 0.5 {
+				t2.Logf("confidence too low: %f", confidence)
+				t2.FailNow()
+			}
+			d, e := dsv.NewDSV(opt)
+			if e != nil {
+				t2.Logf("sniffed options rejected: %v", e)
+				t2.FailNow()
+			}
+			xs := TagTestArray{}
+			if e := d.Deserialize([]byte(tst.Data), &xs); e != nil {
+				t2.Logf("deserialize error: %v", e)
+				t2.FailNow()
+			}
+			if ok, msg := TagTestCmp(&tst.Expect, &xs); !ok {
+				t2.Logf("sniffed dialect mismatch: %s, got=%+v", msg, xs)
+				t2.FailNow()
+			}
+		})
+	}
+	if _, _, e := dsv.Sniff([]byte(" \n")); !errors.Is(e, dsv.DSV_SNIFF_FAILED) {
+		t.Logf("expected sniff failure, got: %v", e)
+		t.FailNow()
+	}
+}
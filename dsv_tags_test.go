@@ -0,0 +1,258 @@
+package dsv_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	dsv "github.com/tony-o/dsv"
+)
+
+type Product struct {
+	Sku  string `csv:"sku,conv=upper"`
+	Name string `csv:"name"`
+}
+
+var upperConverter = dsv.Converter{
+	Serialize: func(i interface{}) ([]byte, bool) {
+		return []byte(strings.ToUpper(i.(string))), true
+	},
+	Deserialize: func(s string, _ []byte) (interface{}, bool) {
+		return strings.ToUpper(strings.TrimSpace(s)), true
+	},
+}
+
+// TestDSV_Tags_Converter ensures a named converter is used for the tagged field only
+func TestDSV_Tags_Converter(t *testing.T) {
+	d := dsv.NewDSVMust(dsv.DSVOpt{
+		Converters: dsv.DConverters(map[string]dsv.Converter{"upper": upperConverter}),
+	})
+	ps := []Product{}
+	if e := d.Deserialize([]byte("sku,name\nab-1,widget"), &ps); e != nil {
+		t.Logf("deserialize error: %v", e)
+		t.FailNow()
+	}
+	if len(ps) != 1 || ps[0].Sku != "AB-1" || ps[0].Name != "widget" {
+		t.Logf("converter mismatch: expected={AB-1 widget},got=%+v", ps)
+		t.FailNow()
+	}
+
+	bs, e := d.Serialize([]Product{{Sku: "cd-2", Name: "gadget"}})
+	if e != nil {
+		t.Logf("serialize error: %v", e)
+		t.FailNow()
+	}
+	if !strings.Contains(string(bs), "CD-2") || !strings.Contains(string(bs), "gadget") {
+		t.Logf("converter not used on serialize: got=%q", string(bs))
+		t.FailNow()
+	}
+}
+
+// TestDSV_Tags_ConverterMissing ensures an unknown converter name is reported
+func TestDSV_Tags_ConverterMissing(t *testing.T) {
+	d := dsv.NewDSVMust(dsv.DSVOpt{})
+	e := d.Deserialize([]byte("sku,name\nab-1,widget"), &[]Product{})
+	if !errors.Is(e, dsv.DSV_CONVERTER_MISSING) {
+		t.Logf("expected missing converter error, got: %v", e)
+		t.FailNow()
+	}
+	_, e = d.Serialize([]Product{{Sku: "cd-2"}})
+	if !errors.Is(e, dsv.DSV_CONVERTER_MISSING) {
+		t.Logf("expected missing converter error, got: %v", e)
+		t.FailNow()
+	}
+}
+
+type Customer struct {
+	Id      int    `csv:"id,required"`
+	Name    string `csv:"name"`
+	Country string `csv:"country,default=US"`
+}
+
+// TestDSV_Tags_Default ensures defaults fill empty cells and missing columns
+func TestDSV_Tags_Default(t *testing.T) {
+	d := dsv.NewDSVMust(dsv.DSVOpt{})
+	cs := []Customer{}
+	if e := d.Deserialize([]byte("id,name,country\n1,a,\n2,b,CA"), &cs); e != nil {
+		t.Logf("deserialize error: %v", e)
+		t.FailNow()
+	}
+	if len(cs) != 2 || cs[0].Country != "US" || cs[1].Country != "CA" {
+		t.Logf("default mismatch: expected=US,CA,got=%+v", cs)
+		t.FailNow()
+	}
+	cs = []Customer{}
+	if e := d.Deserialize([]byte("id,name\n1,a"), &cs); e != nil {
+		t.Logf("deserialize error: %v", e)
+		t.FailNow()
+	}
+	if len(cs) != 1 || cs[0].Country != "US" {
+		t.Logf("default mismatch for missing column: expected=US,got=%+v", cs)
+		t.FailNow()
+	}
+}
+
+// TestDSV_Tags_Required ensures required columns must be present and non-empty
+func TestDSV_Tags_Required(t *testing.T) {
+	d := dsv.NewDSVMust(dsv.DSVOpt{})
+	for _, data := range []string{"name,country\na,CA", "id,name\n1,a\n,b", "name\n", "name"} {
+		e := d.Deserialize([]byte(data), &[]Customer{})
+		if !errors.Is(e, dsv.DSV_REQUIRED_FIELD_MISSING) {
+			t.Logf("expected required field error for %q, got: %v", data, e)
+			t.FailNow()
+		}
+	}
+	if e := d.Deserialize([]byte("id,name\n"), &[]Customer{}); e != nil {
+		t.Logf("unexpected error for a header with the required column and no records: %v", e)
+		t.FailNow()
+	}
+	pd := dsv.NewDSVMust(dsv.DSVOpt{SkipRows: dsv.DInt(1), CommentPrefix: dsv.DByte([]byte("#"))})
+	e := pd.Deserialize([]byte("export\nid,name\n# first\n1,a\n,b"), &[]Customer{})
+	if e == nil || e.Error() != `Required field is missing or empty: record 2: column "id" is empty` {
+		t.Logf("expected the data row to be named past the preamble and comments, got: %v", e)
+		t.FailNow()
+	}
+}
+
+type Account struct {
+	Id       int     `csv:"id"`
+	Email    string  `csv:"email"`
+	Balance  float64 `csv:"balance,omitempty"`
+	Password string  `csv:"password"`
+}
+
+// TestDSV_Tags_OmitEmpty ensures zero values tagged omitempty are written as empty cells
+func TestDSV_Tags_OmitEmpty(t *testing.T) {
+	d := dsv.NewDSVMust(dsv.DSVOpt{IncludeColumns: dsv.DStrings("id", "balance")})
+	bs, e := d.Serialize([]Account{{Id: 1}, {Id: 2, Balance: 1.5}})
+	if e != nil {
+		t.Logf("serialize error: %v", e)
+		t.FailNow()
+	}
+	if string(bs) != "id,balance\n1,\n2,1.500000" {
+		t.Logf("omitempty mismatch: expected=%q,got=%q", "id,balance\n1,\n2,1.500000", string(bs))
+		t.FailNow()
+	}
+}
+
+// TestDSV_Tags_ColumnViews ensures include and exclude lists select the serialized columns
+func TestDSV_Tags_ColumnViews(t *testing.T) {
+	as := []Account{{Id: 1, Email: "a@xyz.com", Balance: 2, Password: "hunter2"}}
+
+	public := dsv.NewDSVMust(dsv.DSVOpt{IncludeColumns: dsv.DStrings("email", "id")})
+	bs, e := public.Serialize(as)
+	if e != nil || string(bs) != "email,id\na@xyz.com,1" {
+		t.Logf("include mismatch: err=%v,got=%q", e, string(bs))
+		t.FailNow()
+	}
+
+	internal := dsv.NewDSVMust(dsv.DSVOpt{ExcludeColumns: dsv.DStrings("password")})
+	bs, e = internal.Serialize(as)
+	if e != nil || strings.Contains(string(bs), "password") || strings.Contains(string(bs), "hunter2") {
+		t.Logf("exclude mismatch: err=%v,got=%q", e, string(bs))
+		t.FailNow()
+	}
+	if !strings.Contains(string(bs), "a@xyz.com") {
+		t.Logf("exclude dropped too much: got=%q", string(bs))
+		t.FailNow()
+	}
+
+	_, e = dsv.NewDSVMust(dsv.DSVOpt{IncludeColumns: dsv.DStrings("nope")}).Serialize(as)
+	if !errors.Is(e, dsv.DSV_COLUMN_NOT_FOUND) {
+		t.Logf("expected unknown column error, got: %v", e)
+		t.FailNow()
+	}
+}
+
+type Positional struct {
+	internal string
+	Note     string
+	Qty      int    `csv:",index=3"`
+	Sku      string `csv:"#1"`
+	Price    float64
+	Name     string `csv:"name,index=0"`
+}
+
+// TestDSV_Tags_Index ensures headerless files bind columns by index tags and skip untagged fields
+func TestDSV_Tags_Index(t *testing.T) {
+	d := dsv.NewDSVMust(dsv.DSVOpt{ParseHeader: dsv.DBool(false)})
+	ps := []Positional{}
+	if e := d.Deserialize([]byte("widget,ab-1,ignored,4\ngadget,cd-2,,7"), &ps); e != nil {
+		t.Logf("deserialize error: %v", e)
+		t.FailNow()
+	}
+	expect := []Positional{{Name: "widget", Sku: "ab-1", Qty: 4}, {Name: "gadget", Sku: "cd-2", Qty: 7}}
+	if len(ps) != len(expect) {
+		t.Logf("row count mismatch: expected=%d,got=%d", len(expect), len(ps))
+		t.FailNow()
+	}
+	for i := range expect {
+		if ps[i] != expect[i] {
+			t.Logf("row %d mismatch: expected=%+v,got=%+v", i, expect[i], ps[i])
+			t.FailNow()
+		}
+	}
+
+	bs, e := d.Serialize(expect)
+	if e != nil {
+		t.Logf("serialize error: %v", e)
+		t.FailNow()
+	}
+	if string(bs) != "widget,ab-1,,4\ngadget,cd-2,,7" {
+		t.Logf("serialize mismatch: expected=%q,got=%q", "widget,ab-1,,4\ngadget,cd-2,,7", string(bs))
+		t.FailNow()
+	}
+}
+
+// TestDSV_Tags_IndexConflict ensures two fields cannot claim the same column
+func TestDSV_Tags_IndexConflict(t *testing.T) {
+	type conflict struct {
+		A string `csv:"#0"`
+		B string `csv:"b,index=0"`
+	}
+	d := dsv.NewDSVMust(dsv.DSVOpt{ParseHeader: dsv.DBool(false)})
+	if e := d.Deserialize([]byte("a"), &[]conflict{}); !errors.Is(e, dsv.DSV_DUPLICATE_TAG_IN_STRUCT) {
+		t.Logf("expected duplicate index error, got: %v", e)
+		t.FailNow()
+	}
+}
+
+// TestDSV_Tags_IndexBounds ensures index tags outside the allowed range are refused before any column is laid out
+func TestDSV_Tags_IndexBounds(t *testing.T) {
+	type huge struct {
+		A string `csv:"#1000000000"`
+	}
+	type negative struct {
+		A string `csv:"a,index=-1"`
+	}
+	type garbled struct {
+		A string `csv:"a,index=x"`
+	}
+	d := dsv.NewDSVMust(dsv.DSVOpt{ParseHeader: dsv.DBool(false)})
+	for _, tgt := range []interface{}{&[]huge{}, &[]negative{}, &[]garbled{}} {
+		if e := d.Deserialize([]byte("a"), tgt); !errors.Is(e, dsv.DSV_INDEX_TAG) {
+			t.Logf("expected %v for %T, got: %v", dsv.DSV_INDEX_TAG, tgt, e)
+			t.FailNow()
+		}
+		if _, e := d.Serialize(tgt); !errors.Is(e, dsv.DSV_INDEX_TAG) {
+			t.Logf("expected %v serializing %T, got: %v", dsv.DSV_INDEX_TAG, tgt, e)
+			t.FailNow()
+		}
+	}
+
+	type named struct {
+		Notes string `csv:"#notes"`
+		Qty   int    `csv:",index=1"`
+	}
+	hd := dsv.NewDSVMust(dsv.DSVOpt{})
+	bs, e := hd.Serialize([]named{{Notes: "n", Qty: 2}})
+	if e != nil || string(bs) != "#notes,#1\nn,2" {
+		t.Logf("expected %q, got: %q (%v)", "#notes,#1\nn,2", bs, e)
+		t.FailNow()
+	}
+	ns := []named{}
+	if e := hd.Deserialize(bs, &ns); e != nil || len(ns) != 1 || ns[0] != (named{Notes: "n", Qty: 2}) {
+		t.Logf("expected the #N header to read back, got: %+v (%v)", ns, e)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,69 @@
+package dsv_test
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	dsv "github.com/tony-o/dsv"
+)
+
+func countColumn(tr [][]string) (int, bool) {
+	if len(tr) == 0 || len(tr[0]) < 2 {
+		return 0, false
+	}
+	i, e := strconv.Atoi(tr[0][1])
+	return i, e == nil
+}
+
+// TestDSV_Trailer_FooterRows ensures trailing records are dropped and returned separately
+func TestDSV_Trailer_FooterRows(t *testing.T) {
+	d := dsv.NewDSVMust(dsv.DSVOpt{FooterRows: dsv.DInt(1)})
+	data := []byte("id,name\n1,a\n2,b\nTOTAL,2")
+	xs := TagTestArray{}
+	tr := [][]string{}
+	if e := d.DeserializeWithTrailer(data, &xs, &tr); e != nil {
+		t.Logf("deserialize error: %v", e)
+		t.FailNow()
+	}
+	if len(xs) != 2 || xs[1].Name != "b" {
+		t.Logf("trailer not removed: got=%+v", xs)
+		t.FailNow()
+	}
+	if len(tr) != 1 || tr[0][0] != "TOTAL" {
+		t.Logf("trailer mismatch: got=%q", tr)
+		t.FailNow()
+	}
+	ms := []map[string]string{}
+	if e := d.Deserialize(data, &ms); e != nil || len(ms) != 2 {
+		t.Logf("trailer not removed from maps: err=%v,got=%+v", e, ms)
+		t.FailNow()
+	}
+}
+
+// TestDSV_Trailer_Match ensures matching records are routed to the trailer and the count is validated
+func TestDSV_Trailer_Match(t *testing.T) {
+	opt := dsv.DSVOpt{
+		TrailerMatch: dsv.DPredicate(func(r []string) bool {
+			return len(r) > 0 && r[0] == "TOTAL"
+		}),
+		TrailerCount: dsv.DTrailerCount(countColumn),
+	}
+	d := dsv.NewDSVMust(opt)
+	xs := TagTestArray{}
+	tr := [][]string{}
+	if e := d.DeserializeWithTrailer([]byte("id,name\n1,a\n2,b\nTOTAL,2\n"), &xs, &tr); e != nil {
+		t.Logf("deserialize error: %v", e)
+		t.FailNow()
+	}
+	if len(xs) != 2 || len(tr) != 1 {
+		t.Logf("trailer routing mismatch: rows=%+v,trailer=%q", xs, tr)
+		t.FailNow()
+	}
+	for _, data := range []string{"id,name\n1,a\nTOTAL,2", "id,name\n1,a"} {
+		if e := d.Deserialize([]byte(data), &TagTestArray{}); !errors.Is(e, dsv.DSV_TRAILER_COUNT_MISMATCH) {
+			t.Logf("expected count mismatch for %q, got: %v", data, e)
+			t.FailNow()
+		}
+	}
+}
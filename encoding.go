@@ -0,0 +1,185 @@
+package dsv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encoding is a character encoding for input or output, data is handled as
+// UTF-8 in between.
+type Encoding int
+
+const (
+	// EncodingUTF8 is the default, the input is not converted.
+	EncodingUTF8 Encoding = iota
+	EncodingUTF16LE
+	EncodingUTF16BE
+	// EncodingLatin1 is ISO-8859-1, each byte is the code point of the same value.
+	EncodingLatin1
+	// EncodingCP1252 is Windows-1252, Latin-1 with printable characters in 0x80-0x9F.
+	EncodingCP1252
+)
+
+type dencoding struct {
+	ok    bool
+	value Encoding
+}
+
+func DEncoding(e Encoding) dencoding {
+	return dencoding{ok: true, value: e}
+}
+
+// InvalidUTF8 is the policy for fields that are not valid UTF-8.
+type InvalidUTF8 int
+
+const (
+	// InvalidUTF8Pass keeps fields as they are.
+	InvalidUTF8Pass InvalidUTF8 = iota
+	// InvalidUTF8Replace replaces each run of invalid bytes with U+FFFD.
+	InvalidUTF8Replace
+	// InvalidUTF8Error fails with DSV_INVALID_UTF8 and the position of the
+	// first invalid byte.
+	InvalidUTF8Error
+)
+
+type dutf8 struct {
+	ok    bool
+	value InvalidUTF8
+}
+
+func DInvalidUTF8(p InvalidUTF8) dutf8 {
+	return dutf8{ok: true, value: p}
+}
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// cp1252 maps 0x80-0x9F to runes, the five bytes Windows leaves undefined map
+// to the C1 control of the same value as browsers do.
+var cp1252 = [32]rune{
+	0x20AC, 0x0081, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008D, 0x017D, 0x008F,
+	0x0090, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0x009D, 0x017E, 0x0178,
+}
+
+// cp1252Bytes is the reverse of cp1252.
+var cp1252Bytes = map[rune]byte{}
+
+func init() {
+	for i, r := range cp1252 {
+		cp1252Bytes[r] = byte(0x80 + i)
+	}
+}
+
+// decode converts s from the input encoding to UTF-8. A byte order mark is
+// removed and, for UTF-16, takes precedence over InputEncoding.
+func (d dsvi) decode(s []byte) ([]byte, error) {
+	enc := d.inputEncoding
+	switch {
+	case bytes.HasPrefix(s, bomUTF8):
+		return s[len(bomUTF8):], nil
+	case bytes.HasPrefix(s, bomUTF16LE):
+		s, enc = s[len(bomUTF16LE):], EncodingUTF16LE
+	case bytes.HasPrefix(s, bomUTF16BE):
+		s, enc = s[len(bomUTF16BE):], EncodingUTF16BE
+	}
+	switch enc {
+	case EncodingUTF16LE, EncodingUTF16BE:
+		if len(s)%2 != 0 {
+			return nil, DSV_DECODE_ERROR.enhance(errors.New("UTF-16 input has an odd number of bytes"))
+		}
+		var order binary.ByteOrder = binary.LittleEndian
+		if enc == EncodingUTF16BE {
+			order = binary.BigEndian
+		}
+		u := make([]uint16, len(s)/2)
+		for i := range u {
+			u[i] = order.Uint16(s[i*2:])
+		}
+		return []byte(string(utf16.Decode(u))), nil
+	case EncodingLatin1, EncodingCP1252:
+		out := make([]byte, 0, len(s))
+		for _, b := range s {
+			r := rune(b)
+			if enc == EncodingCP1252 && b >= 0x80 && b < 0xA0 {
+				r = cp1252[b-0x80]
+			}
+			out = utf8.AppendRune(out, r)
+		}
+		return out, nil
+	}
+	return s, nil
+}
+
+// encode converts UTF-8 output to OutputEncoding, with a byte order mark when
+// WriteBOM is set. Runes the encoding has no byte for are an error.
+func (d dsvi) encode(s []byte) ([]byte, error) {
+	out := []byte{}
+	switch d.outputEncoding {
+	case EncodingUTF8:
+		if d.writeBOM {
+			out = append(out, bomUTF8...)
+		}
+		return append(out, s...), nil
+	case EncodingUTF16LE, EncodingUTF16BE:
+		var order binary.ByteOrder = binary.LittleEndian
+		bom := bomUTF16LE
+		if d.outputEncoding == EncodingUTF16BE {
+			order, bom = binary.BigEndian, bomUTF16BE
+		}
+		if d.writeBOM {
+			out = append(out, bom...)
+		}
+		b := make([]byte, 2)
+		for _, u := range utf16.Encode([]rune(string(s))) {
+			order.PutUint16(b, u)
+			out = append(out, b...)
+		}
+		return out, nil
+	}
+	for i, r := range string(s) {
+		b, ok := byte(r), r < 0x100
+		if d.outputEncoding == EncodingCP1252 {
+			if c, found := cp1252Bytes[r]; found {
+				b, ok = c, true
+			} else if r >= 0x80 && r < 0xA0 {
+				ok = false
+			}
+		}
+		if !ok {
+			return nil, DSV_ENCODE_ERROR.enhance(fmt.Errorf("%q at byte %d has no encoding", r, i))
+		}
+		out = append(out, b)
+	}
+	return out, nil
+}
+
+// validUTF8 applies the InvalidUTF8 policy to the field v, which was read
+// from s starting at off.
+func (d dsvi) validUTF8(s string, off int, v string) (string, error) {
+	if d.invalidUTF8 == InvalidUTF8Pass || utf8.ValidString(v) {
+		return v, nil
+	}
+	if d.invalidUTF8 == InvalidUTF8Replace {
+		return strings.ToValidUTF8(v, "\uFFFD"), nil
+	}
+	i := off
+	for i < len(s) {
+		r, n := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && n <= 1 {
+			break
+		}
+		i += n
+	}
+	line, col := d.position(s, i)
+	return v, DSV_INVALID_UTF8.enhance(fmt.Errorf("byte 0x%02x at line %d, column %d", s[i], line, col))
+}
@@ -17,10 +17,36 @@ var (
 	DSV_INVALID_TARGET_NOT_SLICE = dsvErr{msg: "Invalid target, not a *slice", err: errors.New("Invalid target, not a *slice")}
 	DSV_DESERIALIZE_ERROR        = dsvErr{msg: "Error occurred during deserialize"}
 	DSV_FIELD_NUM_MISMATCH       = dsvErr{msg: "Strict Map option requires all rows have same number of fields"}
+	DSV_REQUIRED_FIELD_MISSING   = dsvErr{msg: "Required field is missing or empty"}
+	DSV_DUPLICATE_HEADER         = dsvErr{msg: "Header contains a duplicate column"}
+	DSV_INVALID_TARGET_MAP       = dsvErr{msg: "Invalid target, maps must be map[string]string or map[string][]string"}
+	DSV_HEADER_MISMATCH          = dsvErr{msg: "Header does not conform to the target struct"}
+	DSV_HEADER_DISABLED          = dsvErr{msg: "ParseHeader must be enabled to check the header", err: errors.New("ParseHeader must be enabled to check the header")}
+	DSV_HEADER_MISSING           = dsvErr{msg: "Input has no header row", err: errors.New("Input has no header row")}
 	DSV_FIELD_DELIMITER_NZ       = dsvErr{msg: "FieldDelimiter must not be zero length", err: errors.New("FieldDelimiter must not be zero length")}
 	DSV_LINE_SEPARATOR_NZ        = dsvErr{msg: "LineSeparator must not be zero length", err: errors.New("LineSeparator must not be zero length")}
+	DSV_HEADER_ROWS_NZ           = dsvErr{msg: "HeaderRows must be at least one", err: errors.New("HeaderRows must be at least one")}
+	DSV_NEGATIVE_ROW_OPTION      = dsvErr{msg: "SkipRows, HeaderRow and FooterRows must not be negative", err: errors.New("SkipRows, HeaderRow and FooterRows must not be negative")}
+	DSV_TRAILER_COUNT_MISMATCH   = dsvErr{msg: "Trailer record count does not match the records parsed"}
+	DSV_SNIFF_FAILED             = dsvErr{msg: "Unable to detect the dialect of the sample"}
+	DSV_UNTERMINATED_QUOTE       = dsvErr{msg: "Quoted field is not terminated"}
+	DSV_MISPLACED_QUOTE          = dsvErr{msg: "Quote does not start or end a field"}
+	DSV_INPUT_TOO_LARGE          = dsvErr{msg: "Input is larger than MaxInputBytes"}
+	DSV_FIELD_TOO_LARGE          = dsvErr{msg: "Field is larger than MaxFieldBytes"}
+	DSV_TOO_MANY_FIELDS          = dsvErr{msg: "Record has more fields than MaxFields"}
+	DSV_TOO_MANY_RECORDS         = dsvErr{msg: "Input has more records than MaxRecords"}
+	DSV_DECODE_ERROR             = dsvErr{msg: "Input could not be decoded"}
+	DSV_ENCODE_ERROR             = dsvErr{msg: "Output could not be encoded"}
+	DSV_INVALID_UTF8             = dsvErr{msg: "Field is not valid UTF-8"}
+	DSV_DECOMPRESS_ERROR         = dsvErr{msg: "Compressed input could not be read"}
+	DSV_ROW_HOOK                 = dsvErr{msg: "Row hook returned an error"}
+	DSV_FIXED_WIDTH_TAG          = dsvErr{msg: "Struct has an invalid fixed width tag"}
+	DSV_INDEX_TAG                = dsvErr{msg: "Struct has an invalid index tag"}
+	DSV_COMMENT_PREFIX_NZ        = dsvErr{msg: "CommentPrefix must not be zero length to write comments", err: errors.New("CommentPrefix must not be zero length to write comments")}
 
 	DSV_SERIALIZER_MISSING = dsvErr{msg: "Serializer requested was not found"}
+	DSV_CONVERTER_MISSING  = dsvErr{msg: "Converter named in tag was not found"}
+	DSV_COLUMN_NOT_FOUND   = dsvErr{msg: "Column requested was not found in struct"}
 )
 
 func (e dsvErr) Error() string {
@@ -34,6 +60,11 @@ func (e dsvErr) Is(t error) bool {
 	return t.Error() == e.msg || strings.HasPrefix(t.Error(), e.msg+": ")
 }
 
+// Unwrap exposes the error behind e, eg the one returned by a row hook.
+func (e dsvErr) Unwrap() error {
+	return e.err
+}
+
 func (e dsvErr) enhance(in error) dsvErr {
 	return dsvErr{msg: e.msg, err: in}
 }
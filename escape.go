@@ -0,0 +1,168 @@
+package dsv
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Escapes is a set of sequences that stand for a byte after the
+// EscapeOperator.
+type Escapes int
+
+const (
+	// EscapesNone keeps the byte after an EscapeOperator as it is.
+	EscapesNone Escapes = iota
+	// EscapesTSV is \n, \r and \t.
+	EscapesTSV
+	// EscapesPostgres is \b, \f, \n, \r, \t and \v as Postgres COPY writes
+	// them, one to three octal digits and \x with one or two hex digits are
+	// also read as the byte they give.
+	EscapesPostgres
+	// EscapesMySQL is \0, \b, \n, \r, \t and \Z (0x1A) as LOAD DATA reads
+	// them.
+	EscapesMySQL
+)
+
+type descapes struct {
+	ok    bool
+	value Escapes
+}
+
+func DEscapes(e Escapes) descapes {
+	return descapes{ok: true, value: e}
+}
+
+// escapeSequences maps the byte following EscapeOperator to the byte it
+// stands for in each set.
+var escapeSequences = map[Escapes]map[byte]byte{
+	EscapesTSV:      {'n': '\n', 'r': '\r', 't': '\t'},
+	EscapesPostgres: {'b': '\b', 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t', 'v': '\v'},
+	EscapesMySQL:    {'0': 0, 'b': '\b', 'n': '\n', 'r': '\r', 't': '\t', 'Z': 0x1a},
+}
+
+// read returns the byte the sequence at the start of s stands for and how
+// many bytes of s it takes.
+func (e Escapes) read(s []byte) (byte, int, bool) {
+	if len(s) == 0 {
+		return 0, 0, false
+	}
+	if c, ok := escapeSequences[e][s[0]]; ok {
+		return c, 1, true
+	}
+	if e != EscapesPostgres {
+		return 0, 0, false
+	}
+	base, digits, max := 8, s, 3
+	if s[0] == 'x' {
+		base, digits, max = 16, s[1:], 2
+	}
+	v, n := 0, 0
+	for n < max && n < len(digits) {
+		d := strings.IndexByte("0123456789abcdef"[:base], digits[n]|0x20)
+		if d < 0 {
+			break
+		}
+		v, n = v*base+d, n+1
+	}
+	if n == 0 {
+		return 0, 0, false
+	}
+	if base == 16 {
+		n++
+	}
+	return byte(v), n, true
+}
+
+// formulaStart holds the bytes that make a spreadsheet read a cell as a formula.
+const formulaStart = "=+-@\t\r"
+
+// SanitizeFormula returns v with a leading ' when it starts with a byte that
+// would make a spreadsheet read it as a formula, for use in serializers. It
+// does not quote v.
+func SanitizeFormula(v []byte) []byte {
+	if len(v) == 0 || strings.IndexByte(formulaStart, v[0]) < 0 {
+		return v
+	}
+	return append([]byte{'\''}, v...)
+}
+
+// isNull reports whether a raw cell, before quotes and escapes are removed,
+// is the NullString.
+func (d dsvi) isNull(raw string) bool {
+	b := []byte(raw)
+	if len(d.stripField) != 0 {
+		b = bytes.Trim(b, string(d.stripField))
+	}
+	return bytes.Equal(b, d.nullString)
+}
+
+// needsQuote reports whether v would not read back as itself if written bare.
+func (d dsvi) needsQuote(v []byte) bool {
+	if d.hasNull && bytes.Equal(v, d.nullString) {
+		return true
+	}
+	if d.sanitizeFormulas && len(v) > 1 && v[0] == '\'' && strings.IndexByte(formulaStart, v[1]) >= 0 {
+		return true
+	}
+	// a bare empty cell would merge with the run of blanks around it
+	if d.whitespaceDelimiter && (len(v) == 0 || bytes.ContainsAny(v, " \t")) {
+		return true
+	}
+	for _, fd := range d.fieldDelimiters {
+		if bytes.Contains(v, fd) {
+			return true
+		}
+	}
+	if bytes.Contains(v, d.fieldOperator) || bytes.Contains(v, d.lineSeparator) || bytes.Contains(v, d.lineEnding) || bytes.ContainsAny(v, "\r\n") {
+		return true
+	}
+	if d.eolen > 0 && bytes.Contains(v, d.escapeOperator) {
+		return true
+	}
+	if d.cplen > 0 && bytes.HasPrefix(v, d.commentPrefix) {
+		return true
+	}
+	return len(v) > 0 && len(d.stripField) > 0 && (bytes.IndexByte(d.stripField, v[0]) >= 0 || bytes.IndexByte(d.stripField, v[len(v)-1]) >= 0)
+}
+
+// escapeField prepares a serialized value for output. With a FieldOperator
+// fields are quoted when needed, or always with QuoteAll, and quotes inside
+// are doubled or escaped. Without one the EscapeOperator is put in front of
+// anything that would end the field early.
+func (d dsvi) escapeField(v []byte) []byte {
+	if d.folen > 0 {
+		if !d.quoteAll && !d.needsQuote(v) {
+			return v
+		}
+		if d.doubleQuote {
+			v = bytes.ReplaceAll(v, d.fieldOperator, d.doubledOperator)
+		} else if d.eolen > 0 {
+			v = bytes.ReplaceAll(v, d.escapeOperator, d.escapedEscape)
+			v = bytes.ReplaceAll(v, d.fieldOperator, d.escapedOperator)
+		}
+		return append(append(append([]byte{}, d.fieldOperator...), v...), d.fieldOperator...)
+	}
+	if d.eolen == 0 {
+		return v
+	}
+	v = bytes.ReplaceAll(v, d.escapeOperator, d.escapedEscape)
+	for k, c := range escapeSequences[d.escapeSequences] {
+		v = bytes.ReplaceAll(v, []byte{c}, append(append([]byte{}, d.escapeOperator...), k))
+	}
+	if d.whitespaceDelimiter {
+		v = bytes.ReplaceAll(v, []byte(" "), append(append([]byte{}, d.escapeOperator...), ' '))
+		v = bytes.ReplaceAll(v, []byte("\t"), append(append([]byte{}, d.escapeOperator...), '\t'))
+	}
+	for _, fd := range d.fieldDelimiters {
+		v = bytes.ReplaceAll(v, fd, append(append([]byte{}, d.escapeOperator...), fd...))
+	}
+	if d.universalNewline {
+		v = bytes.ReplaceAll(v, []byte("\r"), append(append([]byte{}, d.escapeOperator...), '\r'))
+		return bytes.ReplaceAll(v, []byte("\n"), append(append([]byte{}, d.escapeOperator...), '\n'))
+	}
+	v = bytes.ReplaceAll(v, d.lineSeparator, append(append([]byte{}, d.escapeOperator...), d.lineSeparator...))
+	if bytes.Equal(d.lineEnding, d.lineSeparator) {
+		return v
+	}
+	return bytes.ReplaceAll(v, d.lineEnding, append(append([]byte{}, d.escapeOperator...), d.lineEnding...))
+}
@@ -0,0 +1,231 @@
+package dsv
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// fixedSpan is the byte range a field occupies in a FixedWidth record.
+type fixedSpan struct {
+	fi    fieldInfo
+	start int
+	end   int
+	right bool
+	pad   byte
+}
+
+// fixedSpans reads the FixedWidth tag options of fmap. pos=1-10 is bytes one
+// to ten inclusive and pos=11 a single byte, width=10 starts where the field
+// declared before it ends. align=right pads on the left and pad=0 sets the
+// padding byte, a space by default. Fields with neither pos nor width are not
+// part of the record. Spans are returned in the order they appear in a record.
+func fixedSpans(fmap map[string]fieldInfo) ([]fixedSpan, error) {
+	spans := []fixedSpan{}
+	next := 0
+	for _, fi := range sortedFields(fmap) {
+		if fi.pos == "" && fi.width == "" {
+			continue
+		}
+		sp := fixedSpan{fi: fi, start: next, end: next, pad: ' '}
+		if fi.pos != "" {
+			a, b, rng := strings.Cut(fi.pos, "-")
+			start, e := strconv.Atoi(a)
+			end := start
+			if e == nil && rng {
+				end, e = strconv.Atoi(b)
+			}
+			if e != nil || start < 1 || end < start {
+				return nil, DSV_FIXED_WIDTH_TAG.enhance(fmt.Errorf("field %s has pos=%q, expected eg pos=1-10", fi.Name, fi.pos))
+			}
+			sp.start, sp.end = start-1, end
+		}
+		if fi.width != "" {
+			w, e := strconv.Atoi(fi.width)
+			if e != nil || w < 1 {
+				return nil, DSV_FIXED_WIDTH_TAG.enhance(fmt.Errorf("field %s has width=%q", fi.Name, fi.width))
+			}
+			if fi.pos != "" && w != sp.end-sp.start {
+				return nil, DSV_FIXED_WIDTH_TAG.enhance(fmt.Errorf("field %s has pos=%q but width=%d", fi.Name, fi.pos, w))
+			}
+			sp.end = sp.start + w
+		}
+		switch fi.align {
+		case "", "left":
+		case "right":
+			sp.right = true
+		default:
+			return nil, DSV_FIXED_WIDTH_TAG.enhance(fmt.Errorf("field %s has align=%q, expected left or right", fi.Name, fi.align))
+		}
+		if fi.pad != "" {
+			if len(fi.pad) != 1 {
+				return nil, DSV_FIXED_WIDTH_TAG.enhance(fmt.Errorf("field %s has pad=%q, expected a single byte", fi.Name, fi.pad))
+			}
+			sp.pad = fi.pad[0]
+		}
+		spans = append(spans, sp)
+		next = sp.end
+	}
+	sort.SliceStable(spans, func(i, j int) bool {
+		return spans[i].start < spans[j].start
+	})
+	for i := 1; i < len(spans); i++ {
+		if spans[i].start < spans[i-1].end {
+			return nil, DSV_FIXED_WIDTH_TAG.enhance(fmt.Errorf("fields %s and %s overlap", spans[i-1].fi.Name, spans[i].fi.Name))
+		}
+	}
+	return spans, nil
+}
+
+// parseFixed cuts each line of s into the cells described by spans, with the
+// padding removed. Short lines give empty cells.
+func (d dsvi) parseFixed(s string, spans []fixedSpan) (map[int][]string, error) {
+	m := map[int][]string{}
+	if d.maxInputBytes > 0 && len(s) > d.maxInputBytes {
+		return m, DSV_INPUT_TOO_LARGE.enhance(fmt.Errorf("input is %d bytes, the limit is %d", len(s), d.maxInputBytes))
+	}
+	lines, starts := []string{}, []int{}
+	for i := 0; i < len(s); {
+		e, n := d.nextSeparator(s, i)
+		lines, starts = append(lines, s[i:e]), append(starts, i)
+		i = e + n
+	}
+	for k, ln := range lines {
+		if d.cplen > 0 && strings.HasPrefix(ln, string(d.commentPrefix)) {
+			if d.onComment != nil {
+				d.onComment(ln[d.cplen:])
+			}
+			continue
+		}
+		if ln == "" && d.skipEmptyRow {
+			continue
+		}
+		if d.maxRecords > 0 && len(m) >= d.maxRecords {
+			return m, DSV_TOO_MANY_RECORDS.enhance(fmt.Errorf("more than %d records", d.maxRecords))
+		}
+		cells := make([]string, len(spans))
+		for j, sp := range spans {
+			if sp.start >= len(ln) {
+				continue
+			}
+			raw := ln[sp.start:]
+			if sp.end < len(ln) {
+				raw = ln[sp.start:sp.end]
+			}
+			if sp.right {
+				sign := ""
+				if sp.pad == '0' && raw != "" && (raw[0] == '-' || raw[0] == '+') {
+					sign, raw = raw[:1], raw[1:]
+				}
+				cells[j] = strings.TrimLeft(raw, string(sp.pad))
+				// a zero padded zero is all padding
+				if cells[j] == "" && sp.pad != ' ' && raw != "" {
+					cells[j] = raw[len(raw)-1:]
+				}
+				cells[j] = sign + cells[j]
+			} else {
+				cells[j] = strings.TrimRight(raw, string(sp.pad))
+			}
+			v, e := d.validUTF8(s, starts[k]+sp.start, cells[j])
+			if e != nil {
+				return m, e
+			}
+			cells[j] = v
+		}
+		m[len(m)] = cells
+	}
+	return m, nil
+}
+
+// fixedRecord pads or truncates each cell to its span and joins them into a
+// record, gaps between spans are filled with spaces. Cells are cut on a rune
+// boundary and a sign stays in front of zero padding.
+func (d dsvi) fixedRecord(cells [][]byte, spans []fixedSpan) []byte {
+	bs := []byte{}
+	for j, sp := range spans {
+		if len(bs) < sp.start {
+			bs = append(bs, bytes.Repeat([]byte{' '}, sp.start-len(bs))...)
+		}
+		v := cells[j]
+		w := sp.end - sp.start
+		if len(v) > w {
+			n := w
+			for n > 0 && !utf8.RuneStart(v[n]) {
+				n--
+			}
+			v = v[:n]
+		}
+		if sp.right && sp.pad == '0' && len(v) > 0 && (v[0] == '-' || v[0] == '+') {
+			bs, v, w = append(bs, v[0]), v[1:], w-1
+		}
+		pad := bytes.Repeat([]byte{sp.pad}, w-len(v))
+		if sp.right {
+			bs = append(append(bs, pad...), v...)
+		} else {
+			bs = append(append(bs, v...), pad...)
+		}
+	}
+	return append(bs, d.lineEnding...)
+}
+
+// serializeFixed writes the header, when ParseHeader is set, and the records
+// of src as FixedWidth records.
+func (d dsvi) serializeFixed(rs reflect.Value, fmap map[string]fieldInfo) ([]byte, error) {
+	bs := []byte{}
+	spans, e := fixedSpans(fmap)
+	if e != nil {
+		return bs, e
+	}
+	if d.parseHeader {
+		hs := make([]fixedSpan, len(spans))
+		cells := make([][]byte, len(spans))
+		for j, sp := range spans {
+			hs[j] = fixedSpan{start: sp.start, end: sp.end, pad: ' '}
+			cells[j] = []byte(sp.fi.name)
+		}
+		bs = append(bs, d.fixedRecord(cells, hs)...)
+	}
+	row := func(item reflect.Value) error {
+		cells := make([][]byte, len(spans))
+		for j, sp := range spans {
+			v, _, e := d.fieldBytes(item, sp.fi)
+			if e != nil {
+				return e
+			}
+			cells[j] = v
+		}
+		bs = append(bs, d.fixedRecord(cells, spans)...)
+		return nil
+	}
+	if rs.Kind() == reflect.Struct {
+		var e error
+		if rs, e = beforeEncode(rs, 1); e != nil {
+			return bs, e
+		}
+		if e := row(rs); e != nil {
+			return bs, e
+		}
+	} else if rs.Kind() == reflect.Slice {
+		for i := 0; i < rs.Len(); i++ {
+			item := rs.Index(i)
+			for item.Kind() == reflect.Ptr {
+				item = item.Elem()
+			}
+			var e error
+			if item, e = beforeEncode(item, i+1); e != nil {
+				return bs, e
+			}
+			if e := row(item); e != nil {
+				return bs, e
+			}
+		}
+	}
+	if bytes.HasSuffix(bs, d.lineEnding) {
+		bs = bs[:len(bs)-len(d.lineEnding)]
+	}
+	return bs, nil
+}
@@ -0,0 +1,322 @@
+package dsv
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DuplicateHeader is the policy for a header that repeats a column.
+type DuplicateHeader int
+
+const (
+	// DuplicateLastWins keeps the value of the last repeated column.
+	DuplicateLastWins DuplicateHeader = iota
+	// DuplicateFirstWins keeps the value of the first repeated column.
+	DuplicateFirstWins
+	// DuplicateError makes Deserialize fail with DSV_DUPLICATE_HEADER.
+	DuplicateError
+	// DuplicateCollect appends every value to a []string field or map value.
+	DuplicateCollect
+)
+
+type dduplicate struct {
+	ok    bool
+	value DuplicateHeader
+}
+
+func DDuplicate(p DuplicateHeader) dduplicate {
+	return dduplicate{ok: true, value: p}
+}
+
+type dnorm struct {
+	ok    bool
+	value func(string) string
+}
+
+func DNormalizer(f func(string) string) dnorm {
+	return dnorm{ok: true, value: f}
+}
+
+// normalizeHeader applies the opt-in header normalisation to a header cell or
+// tag name, it's a no-op unless one of the header options is set.
+func (d dsvi) normalizeHeader(h string) string {
+	if d.foldHeaderCase {
+		h = strings.ToLower(h)
+	}
+	if d.collapseHeader {
+		h = strings.Join(strings.FieldsFunc(h, func(r rune) bool {
+			return unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r)
+		}), "_")
+	}
+	if d.headerNormalizer != nil {
+		h = d.headerNormalizer(h)
+	}
+	return h
+}
+
+// headerLookup maps each normalised tag name and alias to its field. Two
+// fields whose names normalise to the same key, eg E-mail and e_mail with
+// CollapseHeader, are reported as DSV_DUPLICATE_TAG_IN_STRUCT.
+func (d dsvi) headerLookup(fmap map[string]fieldInfo) (map[string]fieldInfo, error) {
+	lookup := map[string]fieldInfo{}
+	names := map[string]string{}
+	for _, fi := range sortedFields(fmap) {
+		for _, a := range fi.aliases {
+			k := d.normalizeHeader(a)
+			if prev, ok := lookup[k]; ok && prev.name != fi.name {
+				return nil, DSV_DUPLICATE_TAG_IN_STRUCT.enhance(fmt.Errorf("tags '%s' of %s and '%s' of %s both match the header %q", names[k], prev.Name, a, fi.Name, k))
+			}
+			lookup[k] = fi
+			names[k] = a
+		}
+	}
+	return lookup, nil
+}
+
+// headerColumns maps each header cell to the field it populates, unmatched
+// cells map to a zero fieldInfo.
+func (d dsvi) headerColumns(fmap map[string]fieldInfo, header []string) ([]fieldInfo, error) {
+	lookup, e := d.headerLookup(fmap)
+	if e != nil {
+		return nil, e
+	}
+	cols := make([]fieldInfo, len(header))
+	found := map[string]int{}
+	for j, h := range header {
+		fi, ok := lookup[d.normalizeHeader(h)]
+		if !ok {
+			continue
+		}
+		collect := d.duplicateHeaders == DuplicateCollect && fi.Type.Kind() == reflect.Slice && fi.Type.Elem().Kind() == reflect.String
+		if p, dup := found[fi.name]; dup {
+			switch d.duplicateHeaders {
+			case DuplicateError:
+				return cols, DSV_DUPLICATE_HEADER.enhance(fmt.Errorf("column %q appears more than once", h))
+			case DuplicateFirstWins:
+				continue
+			case DuplicateLastWins:
+				cols[p] = fieldInfo{}
+			case DuplicateCollect:
+				if !collect {
+					return cols, DSV_DUPLICATE_HEADER.enhance(fmt.Errorf("column %q appears more than once but %s is not a []string", h, fi.Name))
+				}
+			}
+		}
+		fi.collect = collect
+		cols[j] = fi
+		found[fi.name] = j
+	}
+	for k, fi := range fmap {
+		if _, ok := found[k]; fi.required && !ok {
+			return cols, DSV_REQUIRED_FIELD_MISSING.enhance(fmt.Errorf("column %q is not in the header", k))
+		}
+	}
+	return cols, nil
+}
+
+// HeaderReport describes how a header row conforms to a struct's tags.
+type HeaderReport struct {
+	// Missing lists required columns absent from the header.
+	Missing []string
+	// Extra lists header columns that match no tag.
+	Extra []string
+	// Duplicated lists columns appearing more than once in the header.
+	Duplicated []string
+	// Reordered lists the fewest matched columns that have to move for the
+	// header to follow the struct's field order, eg only name for a header
+	// of name,id against fields id,name.
+	Reordered []string
+}
+
+// OK is true when nothing is missing, extra or duplicated, reordered columns
+// are reported but do not affect deserialization.
+func (r HeaderReport) OK() bool {
+	return len(r.Missing) == 0 && len(r.Extra) == 0 && len(r.Duplicated) == 0
+}
+
+func (r HeaderReport) String() string {
+	return fmt.Sprintf("missing=%q,extra=%q,duplicated=%q,reordered=%q", r.Missing, r.Extra, r.Duplicated, r.Reordered)
+}
+
+// CheckHeader compares the header row of s with the tags of tgt without
+// deserializing any records. Input without a header returns
+// DSV_HEADER_MISSING along with a report listing every required column.
+func (d dsvi) CheckHeader(s []byte, tgt interface{}) (HeaderReport, error) {
+	if !d.parseHeader {
+		return HeaderReport{}, DSV_HEADER_DISABLED
+	}
+	fmap, _, e := ref(tgt)
+	if e != nil {
+		return HeaderReport{}, e
+	}
+	if s, e = d.input(s); e != nil {
+		return HeaderReport{}, e
+	}
+	var lineMap map[int][]string
+	if d.fixedWidth {
+		spans, e := fixedSpans(fmap)
+		if e != nil {
+			return HeaderReport{}, e
+		}
+		if lineMap, e = d.parseFixed(string(s), spans); e != nil {
+			return HeaderReport{}, e
+		}
+	} else if lineMap, _, e = d.parse(string(s)); e != nil {
+		return HeaderReport{}, e
+	}
+	header, _ := d.records(lineMap)
+	if d.fixedWidth {
+		for j := range header {
+			header[j] = strings.TrimSpace(header[j])
+		}
+	}
+	r, e := d.checkHeader(fmap, header)
+	if e == nil && len(header) == 0 {
+		e = DSV_HEADER_MISSING
+	}
+	return r, e
+}
+
+func (d dsvi) checkHeader(fmap map[string]fieldInfo, header []string) (HeaderReport, error) {
+	r := HeaderReport{}
+	lookup, e := d.headerLookup(fmap)
+	if e != nil {
+		return r, e
+	}
+	found := map[string]bool{}
+	matched := []string{}
+	for _, h := range header {
+		fi, ok := lookup[d.normalizeHeader(h)]
+		if !ok {
+			r.Extra = append(r.Extra, h)
+			continue
+		}
+		if found[fi.name] {
+			r.Duplicated = append(r.Duplicated, h)
+			continue
+		}
+		found[fi.name] = true
+		matched = append(matched, fi.name)
+	}
+	order := map[string]int{}
+	for j, fi := range sortedFields(fmap) {
+		order[fi.name] = j
+		if !found[fi.name] && fi.required {
+			r.Missing = append(r.Missing, fi.name)
+		}
+	}
+	pos := make([]int, len(matched))
+	for i, n := range matched {
+		pos[i] = order[n]
+	}
+	for i, keep := range increasing(pos) {
+		if !keep {
+			r.Reordered = append(r.Reordered, matched[i])
+		}
+	}
+	return r, nil
+}
+
+// increasing marks the members of a longest increasing subsequence of pos,
+// the columns left in place when the fewest are moved.
+func increasing(pos []int) []bool {
+	keep := make([]bool, len(pos))
+	tails := []int{}
+	prev := make([]int, len(pos))
+	for i, p := range pos {
+		k := sort.Search(len(tails), func(j int) bool { return pos[tails[j]] >= p })
+		prev[i] = -1
+		if k > 0 {
+			prev[i] = tails[k-1]
+		}
+		if k == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[k] = i
+		}
+	}
+	if len(tails) > 0 {
+		for i := tails[len(tails)-1]; i >= 0; i = prev[i] {
+			keep[i] = true
+		}
+	}
+	return keep
+}
+
+// deserializeMaps fills a *[]map[string]string or *[]map[string][]string target
+// keyed by header, or by column number without ParseHeader. Null cells are
+// left out of the map.
+func (d dsvi) deserializeMaps(rs reflect.Value, lineMap map[int][]string, nulls map[[2]int]bool, header []string, rows []int) error {
+	mt := rs.Type().Elem()
+	vt := mt.Elem()
+	multi := vt.Kind() == reflect.Slice
+	if multi {
+		vt = vt.Elem()
+	}
+	if mt.Key().Kind() != reflect.String || vt.Kind() != reflect.String {
+		return DSV_INVALID_TARGET_MAP.enhance(fmt.Errorf("got:%s", mt))
+	}
+	keys := make([]string, len(header))
+	seen := map[string]bool{}
+	for j, h := range header {
+		keys[j] = d.normalizeHeader(h)
+		if seen[keys[j]] {
+			if d.duplicateHeaders == DuplicateError {
+				return DSV_DUPLICATE_HEADER.enhance(fmt.Errorf("column %q appears more than once", h))
+			}
+			if d.duplicateHeaders == DuplicateCollect && !multi {
+				return DSV_DUPLICATE_HEADER.enhance(fmt.Errorf("column %q appears more than once but %s cannot collect values", h, mt))
+			}
+		}
+		seen[keys[j]] = true
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+	iln := len(header)
+	if !d.parseHeader {
+		iln = len(lineMap[rows[0]])
+	}
+	rs.Set(reflect.MakeSlice(rs.Type(), len(rows), len(rows)))
+	for ri, i := range rows {
+		ln := lineMap[i]
+		if len(ln) != iln && d.strictMap {
+			return DSV_FIELD_NUM_MISMATCH.enhance(fmt.Errorf("StrictMap requires all rows have same number of fields, expected=%d,got=%d", iln, len(ln)))
+		}
+		m := reflect.MakeMap(mt)
+		for j, r := range ln {
+			if nulls[[2]int{i, j}] {
+				continue
+			}
+			k := strconv.Itoa(j)
+			if d.parseHeader {
+				if j >= len(keys) {
+					break
+				}
+				k = keys[j]
+			}
+			kv := reflect.ValueOf(k).Convert(mt.Key())
+			rv := reflect.ValueOf(r).Convert(vt)
+			if cur := m.MapIndex(kv); cur.IsValid() {
+				if d.duplicateHeaders == DuplicateFirstWins {
+					continue
+				}
+				if d.duplicateHeaders == DuplicateCollect {
+					m.SetMapIndex(kv, reflect.Append(cur, rv))
+					continue
+				}
+			}
+			if multi {
+				rv = reflect.Append(reflect.MakeSlice(mt.Elem(), 0, 1), rv)
+			}
+			m.SetMapIndex(kv, rv)
+		}
+		rs.Index(ri).Set(m)
+	}
+	return nil
+}
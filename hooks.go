@@ -0,0 +1,61 @@
+package dsv
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// AfterDSVDecoder is implemented by row types that derive fields or check
+// invariants across fields once Deserialize has filled the row. An error from
+// either hook is wrapped in DSV_ROW_HOOK naming the record as its data row
+// counted from 1, the header, skipped rows and comments are not counted.
+type AfterDSVDecoder interface {
+	AfterDSVDecode() error
+}
+
+// BeforeDSVEncoder is implemented by row types that prepare fields before
+// Serialize writes the row. The hook runs on a copy of each row, whether it is
+// passed alone, in a slice or through a pointer, so a pointer receiver may
+// change what is written without changing the caller's data.
+type BeforeDSVEncoder interface {
+	BeforeDSVEncode() error
+}
+
+var beforeDSVEncoderType = reflect.TypeOf((*BeforeDSVEncoder)(nil)).Elem()
+
+// rowHook returns row as an interface the hooks can be found on, through its
+// address when it has one so methods with a pointer receiver are included.
+func rowHook(row reflect.Value) interface{} {
+	if row.CanAddr() {
+		return row.Addr().Interface()
+	}
+	return row.Interface()
+}
+
+// afterDecode calls AfterDSVDecode on a filled row, record is its data row
+// counted from 1.
+func afterDecode(row reflect.Value, record int) error {
+	if h, ok := rowHook(row).(AfterDSVDecoder); ok {
+		if e := h.AfterDSVDecode(); e != nil {
+			return DSV_ROW_HOOK.enhance(fmt.Errorf("AfterDSVDecode on record %d: %w", record, e))
+		}
+	}
+	return nil
+}
+
+// beforeEncode calls BeforeDSVEncode on a row about to be written, record is
+// its data row counted from 1. It returns the row to write, a copy when the
+// row has a hook.
+func beforeEncode(row reflect.Value, record int) (reflect.Value, error) {
+	if reflect.PtrTo(row.Type()).Implements(beforeDSVEncoderType) {
+		cp := reflect.New(row.Type()).Elem()
+		cp.Set(row)
+		row = cp
+	}
+	if h, ok := rowHook(row).(BeforeDSVEncoder); ok {
+		if e := h.BeforeDSVEncode(); e != nil {
+			return row, DSV_ROW_HOOK.enhance(fmt.Errorf("BeforeDSVEncode on record %d: %w", record, e))
+		}
+	}
+	return row, nil
+}
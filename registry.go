@@ -0,0 +1,178 @@
+package dsv
+
+import (
+	"reflect"
+)
+
+// builtinTypes resolves the legacy string keys accepted by DSVOpt.Serializers
+// and DSVOpt.Deserializers to a reflect.Type where the name is unambiguous.
+var builtinTypes = map[string]reflect.Type{}
+
+// kindTypes maps a basic kind to the predeclared type used as a fallback for
+// named types, eg `type Cents int64` falls back to the int64 converter.
+var kindTypes = map[reflect.Kind]reflect.Type{}
+
+func init() {
+	for _, t := range []reflect.Type{
+		reflect.TypeOf(false),
+		reflect.TypeOf(""),
+		reflect.TypeOf(int(0)),
+		reflect.TypeOf(int8(0)),
+		reflect.TypeOf(int16(0)),
+		reflect.TypeOf(int32(0)),
+		reflect.TypeOf(int64(0)),
+		reflect.TypeOf(uint(0)),
+		reflect.TypeOf(uint8(0)),
+		reflect.TypeOf(uint16(0)),
+		reflect.TypeOf(uint32(0)),
+		reflect.TypeOf(uint64(0)),
+		reflect.TypeOf(uintptr(0)),
+		reflect.TypeOf(float32(0)),
+		reflect.TypeOf(float64(0)),
+		reflect.TypeOf(complex64(0)),
+		reflect.TypeOf(complex128(0)),
+	} {
+		kindTypes[t.Kind()] = t
+		builtinTypes[t.String()] = t
+		builtinTypes[reflect.SliceOf(t).String()] = reflect.SliceOf(t)
+	}
+	builtinTypes["byte"] = reflect.TypeOf(byte(0))
+	builtinTypes["rune"] = reflect.TypeOf(rune(0))
+	builtinTypes["[]byte"] = reflect.TypeOf([]byte{})
+	builtinTypes["[]rune"] = reflect.TypeOf([]rune{})
+}
+
+// addNamed adds converters keyed by type name. Names of predeclared types
+// resolve to the type, any other name is matched against the name of the
+// field's type.
+func (d dsvi) addNamed(ser map[string]func(interface{}) ([]byte, bool), deser map[string]func(string, []byte) (interface{}, bool)) {
+	for k, v := range ser {
+		if t, ok := builtinTypes[k]; ok {
+			d.serializers[t] = v
+		} else {
+			d.serializersByName[k] = v
+		}
+	}
+	for k, v := range deser {
+		if t, ok := builtinTypes[k]; ok {
+			d.deserializers[t] = v
+		} else {
+			d.deserializersByName[k] = v
+		}
+	}
+}
+
+// RegisterType adds a serializer and/or deserializer for T to opt, either
+// function may be nil. Converters registered this way are keyed by the
+// reflect.Type of T so identically named types in different packages do not
+// collide.
+func RegisterType[T any](opt *DSVOpt, ser func(T) ([]byte, bool), deser func(string, []byte) (T, bool)) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if ser != nil {
+		if !opt.TypeSerializers.ok || opt.TypeSerializers.value == nil {
+			opt.TypeSerializers = DTSerial(map[reflect.Type]func(interface{}) ([]byte, bool){})
+		}
+		opt.TypeSerializers.value[t] = func(i interface{}) ([]byte, bool) {
+			v, ok := i.(T)
+			if !ok {
+				return []byte{}, false
+			}
+			return ser(v)
+		}
+	}
+	if deser != nil {
+		if !opt.TypeDeserializers.ok || opt.TypeDeserializers.value == nil {
+			opt.TypeDeserializers = DTDeserial(map[reflect.Type]func(string, []byte) (interface{}, bool){})
+		}
+		opt.TypeDeserializers.value[t] = func(s string, bs []byte) (interface{}, bool) {
+			return deser(s, bs)
+		}
+	}
+}
+
+// underlyingType returns the predeclared type sharing t's underlying
+// representation, or nil if there is none.
+func underlyingType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Slice {
+		if e := underlyingType(t.Elem()); e != nil {
+			return reflect.SliceOf(e)
+		}
+		return nil
+	}
+	return kindTypes[t.Kind()]
+}
+
+func (d dsvi) deserializerFor(t reflect.Type) (func(string, []byte) (interface{}, bool), bool) {
+	if f, ok := d.deserializers[t]; ok {
+		return f, true
+	}
+	if f, ok := d.deserializersByName[t.String()]; ok {
+		return f, true
+	}
+	if u := underlyingType(t); u != nil && u != t {
+		if f, ok := d.deserializers[u]; ok {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+// serializerFor returns the serializer for t and the type a value must be
+// converted to before it is handed to the serializer.
+func (d dsvi) serializerFor(t reflect.Type) (func(interface{}) ([]byte, bool), reflect.Type, bool) {
+	if f, ok := d.serializers[t]; ok {
+		return f, t, true
+	}
+	if f, ok := d.serializersByName[t.String()]; ok {
+		return f, t, true
+	}
+	if u := underlyingType(t); u != nil && u != t {
+		if f, ok := d.serializers[u]; ok {
+			return f, u, true
+		}
+	}
+	return nil, t, false
+}
+
+// deref follows pointers without a serializer of their own to the value
+// they point at, a nil pointer gives the zero Value.
+func (d dsvi) deref(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr {
+		if _, _, ok := d.serializerFor(v.Type()); ok {
+			return v
+		}
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// numericClass groups kinds that may be converted between one another.
+func numericClass(k reflect.Kind) int {
+	switch {
+	case k >= reflect.Int && k <= reflect.Float64:
+		return 1
+	case k == reflect.Complex64 || k == reflect.Complex128:
+		return 2
+	}
+	return 0
+}
+
+// setValue assigns v to fs, converting between named types and numeric widths
+// but never across kinds (eg int->string rune conversions).
+func setValue(fs reflect.Value, v interface{}) {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		fs.Set(reflect.Zero(fs.Type()))
+		return
+	}
+	if rv.Type() != fs.Type() && rv.Type().ConvertibleTo(fs.Type()) {
+		rc := numericClass(rv.Kind())
+		if rv.Kind() == fs.Kind() || (rc != 0 && rc == numericClass(fs.Kind())) {
+			rv = rv.Convert(fs.Type())
+		}
+	}
+	fs.Set(rv)
+}
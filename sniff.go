@@ -0,0 +1,191 @@
+package dsv
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var (
+	sniffDelimiters = []string{",", "\t", ";", "|"}
+	sniffOperators  = []string{"\"", "'"}
+	sniffBoundary   = ",\t;|\r\n"
+)
+
+// Sniff infers FieldDelimiter, LineSeparator, FieldOperator, EscapeOperator,
+// DoubleQuote and ParseHeader from a sample of the data, the returned options can be
+// passed straight to NewDSV. Confidence is between 0 and 1 and is the share of
+// sampled records agreeing on the number of fields, reduced when the sample
+// only ever has one column.
+func Sniff(sample []byte) (DSVOpt, float64, error) {
+	opt := DSVOpt{}
+	if len(bytes.TrimSpace(sample)) == 0 {
+		return opt, 0, DSV_SNIFF_FAILED.enhance(errors.New("sample is empty"))
+	}
+	ls := "\n"
+	if bytes.Contains(sample, []byte("\r\n")) {
+		ls = "\r\n"
+	} else if bytes.Contains(sample, []byte("\r")) && !bytes.Contains(sample, []byte("\n")) {
+		ls = "\r"
+	}
+	// the sample is likely cut mid record, drop everything after the last
+	// line separator so a partial record does not skew the field counts
+	if i := bytes.LastIndex(sample, []byte(ls)); i > 0 && i < len(sample)-len(ls) {
+		sample = sample[:i]
+	}
+
+	fo := sniffOperator(sample)
+	eo := "\\"
+	dq := fo != "" && sniffDoubled(sample, fo)
+	if dq {
+		eo = ""
+	}
+
+	var bestFd string
+	var bestRecords map[int][]string
+	bestScore := -1.0
+	for _, fd := range sniffDelimiters {
+		d, e := NewDSV(DSVOpt{
+			FieldDelimiter: DString(fd),
+			LineSeparator:  DString(ls),
+			FieldOperator:  DString(fo),
+			EscapeOperator: DString(eo),
+			DoubleQuote:    DBool(dq),
+			StripField:     DString(""),
+		})
+		if e != nil {
+			continue
+		}
+		m, e := d.DeserializeMapIndex(string(sample))
+		if e != nil || len(m) == 0 {
+			continue
+		}
+		counts := []int{}
+		for i := 0; i < len(m); i++ {
+			counts = append(counts, len(m[i]))
+		}
+		mode, n := sniffMode(counts)
+		score := float64(n) / float64(len(counts))
+		if mode < 2 {
+			score /= 10
+		}
+		if score > bestScore {
+			bestScore, bestFd, bestRecords = score, fd, m
+		}
+	}
+	if bestRecords == nil {
+		return opt, 0, DSV_SNIFF_FAILED.enhance(errors.New("no candidate delimiter could parse the sample"))
+	}
+
+	opt.FieldDelimiter = DString(bestFd)
+	opt.LineSeparator = DString(ls)
+	opt.FieldOperator = DString(fo)
+	opt.EscapeOperator = DString(eo)
+	opt.DoubleQuote = DBool(dq)
+	opt.ParseHeader = DBool(sniffHeader(bestRecords))
+	return opt, bestScore, nil
+}
+
+// sniffOperator picks the quote character most often found next to a
+// candidate delimiter or line break, or "" when the sample is unquoted.
+func sniffOperator(sample []byte) string {
+	best, bestN := "", 1
+	for _, q := range sniffOperators {
+		n := 0
+		for i := 0; i < len(sample); i++ {
+			if sample[i] != q[0] {
+				continue
+			}
+			if i == 0 || bytes.IndexByte([]byte(sniffBoundary), sample[i-1]) >= 0 {
+				n++
+			}
+			if i == len(sample)-1 || bytes.IndexByte([]byte(sniffBoundary), sample[i+1]) >= 0 {
+				n++
+			}
+		}
+		if n > bestN {
+			best, bestN = q, n
+		}
+	}
+	return best
+}
+
+// sniffDoubled reports whether quotes are escaped by doubling them, as
+// RFC 4180 does, rather than with a backslash.
+func sniffDoubled(sample []byte, fo string) bool {
+	s := string(sample)
+	qq := fo + fo
+	for i := 0; i+len(qq) <= len(s); i++ {
+		if s[i:i+len(qq)] != qq {
+			continue
+		}
+		before := i == 0 || strings.ContainsRune(sniffBoundary, rune(s[i-1]))
+		after := i+len(qq) == len(s) || strings.ContainsRune(sniffBoundary, rune(s[i+len(qq)]))
+		if !(before && after) {
+			return true
+		}
+		i += len(qq) - 1
+	}
+	return false
+}
+
+// sniffMode returns the most common value in counts and how often it occurs.
+func sniffMode(counts []int) (int, int) {
+	freq := map[int]int{}
+	mode, n := 0, 0
+	for _, c := range counts {
+		freq[c]++
+		if freq[c] > n || (freq[c] == n && c > mode) {
+			mode, n = c, freq[c]
+		}
+	}
+	return mode, n
+}
+
+// sniffHeader votes per column on whether the first record looks different
+// from the rest, either numeric against text or a differing fixed length.
+func sniffHeader(m map[int][]string) bool {
+	if len(m) < 2 {
+		return true
+	}
+	votes := 0
+	for j := range m[0] {
+		numeric, lengths := 0, map[int]bool{}
+		rest := 0
+		for i := 1; i < len(m); i++ {
+			if j >= len(m[i]) {
+				continue
+			}
+			rest++
+			if sniffNumeric(m[i][j]) {
+				numeric++
+			}
+			lengths[len(m[i][j])] = true
+		}
+		if rest == 0 {
+			continue
+		}
+		h := m[0][j]
+		switch {
+		case numeric == rest && !sniffNumeric(h):
+			votes++
+		case numeric == rest && sniffNumeric(h):
+			votes--
+		case len(lengths) == 1 && !lengths[len(h)]:
+			votes++
+		case len(lengths) == 1:
+			votes--
+		}
+	}
+	return votes > 0
+}
+
+func sniffNumeric(s string) bool {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return false
+	}
+	_, e := strconv.ParseFloat(s, 64)
+	return e == nil
+}
@@ -0,0 +1,187 @@
+package dsv
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// fieldInfo is a struct field along with the options parsed from its csv tag,
+// eg `csv:"sku,conv=upper"`. Alternative header names are separated by a pipe,
+// eg `csv:"email|e-mail"`, the first is used when serializing. A column
+// position for headerless files is given with `csv:",index=3"` or `csv:"#3"`,
+// up to maxColumnIndex. A field with only a position is named #3, which is
+// the header Serialize writes and Deserialize matches when ParseHeader is on.
+// FixedWidth files use pos, width, align and pad, see fixedSpans.
+type fieldInfo struct {
+	reflect.StructField
+	name      string
+	aliases   []string
+	conv      string
+	def       string
+	hasDef    bool
+	required  bool
+	omitEmpty bool
+	index     int
+	hasIndex  bool
+	collect   bool
+	pos       string
+	width     string
+	align     string
+	pad       string
+}
+
+// Converter is a named pair of functions selected per field with the conv tag
+// option. A nil Serialize or Deserialize falls back to the type's converter.
+type Converter struct {
+	Serialize   func(interface{}) ([]byte, bool)
+	Deserialize func(string, []byte) (interface{}, bool)
+}
+
+type dconv struct {
+	ok    bool
+	value map[string]Converter
+}
+
+func DConverters(m map[string]Converter) dconv {
+	return dconv{ok: true, value: m}
+}
+
+// parseTag splits a csv tag into its column name and key[=value] options.
+func parseTag(tag string) (string, map[string]string) {
+	parts := strings.Split(tag, ",")
+	opts := map[string]string{}
+	for _, p := range parts[1:] {
+		if kv := strings.SplitN(p, "=", 2); len(kv) == 2 {
+			opts[strings.TrimSpace(kv[0])] = kv[1]
+		} else {
+			opts[strings.TrimSpace(p)] = ""
+		}
+	}
+	return parts[0], opts
+}
+
+func parseField(sf reflect.StructField) (fieldInfo, error) {
+	name, opts := parseTag(sf.Tag.Get("csv"))
+	index, hasIndex := -1, false
+	v, ok := opts["index"]
+	if !ok && strings.HasPrefix(name, "#") {
+		// a name such as #notes is a header, not a position
+		_, e := strconv.Atoi(strings.TrimSpace(name[1:]))
+		v, ok = name[1:], e == nil
+	}
+	if ok {
+		var e error
+		if index, e = parseIndex(v); e != nil {
+			return fieldInfo{}, DSV_INDEX_TAG.enhance(fmt.Errorf("field %s: %w", sf.Name, e))
+		}
+		hasIndex = true
+	}
+	if name == "" && hasIndex {
+		name = "#" + strconv.Itoa(index)
+	}
+	aliases := strings.Split(name, "|")
+	def, hasDef := opts["default"]
+	_, required := opts["required"]
+	_, omitEmpty := opts["omitempty"]
+	return fieldInfo{
+		StructField: sf,
+		name:        aliases[0],
+		aliases:     aliases,
+		conv:        opts["conv"],
+		def:         def,
+		hasDef:      hasDef,
+		required:    required,
+		omitEmpty:   omitEmpty,
+		index:       index,
+		hasIndex:    hasIndex,
+		pos:         opts["pos"],
+		width:       opts["width"],
+		align:       opts["align"],
+		pad:         opts["pad"],
+	}, nil
+}
+
+// sortedFields returns the fields of fmap in struct declaration order.
+func sortedFields(fmap map[string]fieldInfo) []fieldInfo {
+	fields := make([]fieldInfo, 0, len(fmap))
+	for _, fi := range fmap {
+		fields = append(fields, fi)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Index[0] < fields[j].Index[0]
+	})
+	return fields
+}
+
+// maxColumnIndex bounds index tags, positionColumns allocates a column for
+// every position up to the largest.
+const maxColumnIndex = 1 << 16
+
+func parseIndex(s string) (int, error) {
+	i, e := strconv.Atoi(strings.TrimSpace(s))
+	if e != nil {
+		return -1, e
+	}
+	if i < 0 || i > maxColumnIndex {
+		return -1, fmt.Errorf("index %d is outside 0 to %d", i, maxColumnIndex)
+	}
+	return i, nil
+}
+
+// positionColumns lays the fields out by column position for headerless
+// files. A field without an explicit index takes the position after the
+// previous tagged field in declaration order.
+func positionColumns(fmap map[string]fieldInfo) ([]fieldInfo, error) {
+	fields := sortedFields(fmap)
+	cols := []fieldInfo{}
+	next := 0
+	for _, fi := range fields {
+		idx := next
+		if fi.hasIndex {
+			idx = fi.index
+		}
+		for len(cols) <= idx {
+			cols = append(cols, fieldInfo{})
+		}
+		if cols[idx].Name != "" {
+			return nil, DSV_DUPLICATE_TAG_IN_STRUCT.enhance(fmt.Errorf("Column index %d is used by both %s and %s", idx, cols[idx].Name, fi.Name))
+		}
+		cols[idx] = fi
+		next = idx + 1
+	}
+	return cols, nil
+}
+
+func (d dsvi) fieldDeserializer(fi fieldInfo, t reflect.Type) (func(string, []byte) (interface{}, bool), error) {
+	if fi.conv != "" {
+		c, ok := d.converters[fi.conv]
+		if !ok {
+			return nil, DSV_CONVERTER_MISSING.enhance(fmt.Errorf("Unable to find converter %q for field %s", fi.conv, fi.Name))
+		}
+		if c.Deserialize != nil {
+			return c.Deserialize, nil
+		}
+	}
+	f, _ := d.deserializerFor(t)
+	return f, nil
+}
+
+func (d dsvi) fieldSerializer(fi fieldInfo, t reflect.Type) (func(interface{}) ([]byte, bool), reflect.Type, error) {
+	if fi.conv != "" {
+		c, ok := d.converters[fi.conv]
+		if !ok {
+			return nil, t, DSV_CONVERTER_MISSING.enhance(fmt.Errorf("Unable to find converter %q for field %s", fi.conv, fi.Name))
+		}
+		if c.Serialize != nil {
+			return c.Serialize, t, nil
+		}
+	}
+	f, ty, ok := d.serializerFor(t)
+	if !ok {
+		return nil, t, DSV_SERIALIZER_MISSING.enhance(fmt.Errorf("Unable to find handler for type: %s", t))
+	}
+	return f, ty, nil
+}
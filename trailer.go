@@ -0,0 +1,52 @@
+package dsv
+
+import (
+	"errors"
+	"fmt"
+)
+
+type dtrailer struct {
+	ok    bool
+	value func([][]string) (int, bool)
+}
+
+// DTrailerCount sets a function that finds the record count declared in the
+// trailer, Deserialize fails with DSV_TRAILER_COUNT_MISMATCH unless it matches
+// the number of data records.
+func DTrailerCount(f func([][]string) (int, bool)) dtrailer {
+	return dtrailer{ok: true, value: f}
+}
+
+// DeserializeWithTrailer is Deserialize but also stores the trailer records,
+// those dropped by FooterRows or matched by TrailerMatch, in trailer.
+func (d dsvi) DeserializeWithTrailer(s []byte, tgt interface{}, trailer *[][]string) error {
+	return d.deserialize(s, tgt, trailer)
+}
+
+// splitTrailer removes the trailer from rows and validates the declared
+// record count when TrailerCount is set.
+func (d dsvi) splitTrailer(lineMap map[int][]string, rows []int, trailer *[][]string) ([]int, error) {
+	tr := [][]string{}
+	data := []int{}
+	n := len(rows) - d.footerRows
+	for k, i := range rows {
+		if k >= n || (d.trailerMatch != nil && d.trailerMatch(lineMap[i])) {
+			tr = append(tr, lineMap[i])
+		} else {
+			data = append(data, i)
+		}
+	}
+	if d.trailerCount != nil {
+		c, ok := d.trailerCount(tr)
+		if !ok {
+			return data, DSV_TRAILER_COUNT_MISMATCH.enhance(errors.New("no record count found in the trailer"))
+		}
+		if c != len(data) {
+			return data, DSV_TRAILER_COUNT_MISMATCH.enhance(fmt.Errorf("trailer declares %d records, parsed %d", c, len(data)))
+		}
+	}
+	if trailer != nil {
+		*trailer = tr
+	}
+	return data, nil
+}